@@ -0,0 +1,205 @@
+package log2
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// lumberjackWriter 由lumberjack写入，切分出的文件的压缩及清理改为在后台完成，以支持zstd及切分回调
+// lumberjack没有切分回调，按与lumberjack相同的规则推算是否切分
+type lumberjackWriter struct {
+	*lumberjack.Logger
+	source     *lumberjackSource
+	config     *RotateConfig
+	compressor *compressor
+	onArchived func(path string)
+	busy       *busyFiles // 正在压缩或者等待上传的文件，清理时跳过
+	maxBackups int
+	maxAge     int
+
+	lock     sync.Mutex
+	opened   bool
+	size     int64
+	seen     map[string]bool // 已通知或者启动前已存在的文件
+	millLock sync.Mutex      // 串行执行压缩及清理
+	milling  sync.WaitGroup
+}
+
+/*
+newLumberjackWriter 新建lumberjack的包装，lumberjack自身的清理会被关闭
+参数:
+*	logger    	*lumberjack.Logger	lumberjack
+*	config    	*RotateConfig     	切分配置
+*	compressor	*compressor       	共用的压缩协程，不压缩时为空
+*	onArchived	func(path string) 	切分出的文件完成后的处理，为空时不处理
+返回值:
+*	*lumberjackWriter	*lumberjackWriter	writer
+*/
+func newLumberjackWriter(logger *lumberjack.Logger, config *RotateConfig, compressor *compressor, onArchived func(path string)) *lumberjackWriter {
+	result := &lumberjackWriter{
+		Logger:     logger,
+		source:     newLumberjackSource(logger, config.compression()),
+		config:     config,
+		compressor: compressor,
+		onArchived: onArchived,
+		maxBackups: logger.MaxBackups,
+		maxAge:     logger.MaxAge,
+	}
+
+	logger.Compress = false
+	logger.MaxBackups = 0
+	logger.MaxAge = 0
+
+	return result
+}
+
+func (w *lumberjackWriter) maxSize() int64 {
+	return int64(w.MaxSize) * 1024 * 1024
+}
+
+func (w *lumberjackWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+
+	if w.seen == nil {
+		w.seen = make(map[string]bool)
+		w.markSeen()
+	}
+
+	rotated := false
+
+	if !w.opened {
+		// 与lumberjack打开已有文件的规则一致
+		if info, err := os.Stat(w.Filename); err == nil {
+			w.size = info.Size()
+			rotated = w.size+int64(len(data)) >= w.maxSize()
+		} else {
+			w.size = 0
+		}
+
+		w.opened = true
+	} else {
+		rotated = w.size+int64(len(data)) > w.maxSize()
+	}
+
+	if rotated {
+		w.size = 0
+	}
+
+	n, err := w.Logger.Write(data)
+	w.size += int64(n)
+	w.lock.Unlock()
+
+	// lumberjack切分时同步重命名，返回后切分出的文件已经存在
+	if rotated && err == nil {
+		w.startMill()
+	}
+
+	return n, err
+}
+
+// markSeen 记录已存在的文件，启动前的文件只压缩及清理，不通知
+func (w *lumberjackWriter) markSeen() {
+	files, err := w.source.archives()
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		w.seen[file.path] = true
+	}
+}
+
+func (w *lumberjackWriter) startMill() {
+	w.milling.Add(1)
+
+	go func() {
+		defer w.milling.Done()
+
+		if err := w.mill(); err != nil {
+			debugPrintln(`压缩及清理日志文件`, err)
+		}
+	}()
+}
+
+// mill 删除超过MaxBackups及MaxAge的文件，压缩未压缩的文件，再通知新切分出的文件
+func (w *lumberjackWriter) mill() error {
+	w.millLock.Lock()
+	defer w.millLock.Unlock()
+
+	files, err := w.source.archives()
+	if err != nil {
+		return err
+	}
+
+	remains, err := removeExpired(files, w.maxBackups, w.maxAge, time.Now(), w.busy)
+	codec := w.config.compression()
+
+	for _, file := range remains {
+		path := file.path
+
+		if codec != CompressionNone && compressedExtOf(path) == `` {
+			target, compressErr := w.compressor.compress(path, codec, w.config.CompressionLevel)
+			if compressErr != nil {
+				err = multierr.Append(err, compressErr)
+			} else {
+				path = target
+			}
+		}
+
+		w.lock.Lock()
+		notify := !w.seen[file.path]
+		w.seen[file.path] = true
+		w.seen[path] = true
+		w.lock.Unlock()
+
+		if notify && w.onArchived != nil {
+			w.onArchived(path)
+		}
+	}
+
+	// 已删除及压缩前的文件不会再出现，不再记录
+	removeMissing(&w.lock, w.seen)
+
+	return err
+}
+
+// Close 关闭文件并等待后台的压缩及清理完成，之后再次写入会重新打开
+func (w *lumberjackWriter) Close() error {
+	w.lock.Lock()
+	w.opened = false
+	w.lock.Unlock()
+
+	err := w.Logger.Close()
+
+	w.milling.Wait()
+
+	return err
+}
+
+/*
+archivedHandler 切分出的文件完成后的处理，先调用RotateConfig.OnRotate再交给上传
+参数:
+*	onRotate	func(string)	RotateConfig.OnRotate
+*	uploader	*uploader   	上传，为空时不上传
+返回值:
+*	func(string)	func(string)	处理函数，都为空时返回nil
+*/
+func archivedHandler(onRotate func(string), uploader *uploader) func(string) {
+	if onRotate == nil && uploader == nil {
+		return nil
+	}
+
+	return func(path string) {
+		if onRotate != nil {
+			onRotate(path)
+		}
+
+		if uploader != nil {
+			uploader.enqueue(path)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package log2
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// TestLumberjackWriter_Seen 测试被清理的文件不再留在已通知的记录中
+func TestLumberjackWriter_Seen(t *testing.T) {
+	dir := t.TempDir()
+
+	var archived []string
+
+	writer := newLumberjackWriter(&lumberjack.Logger{Filename: filepath.Join(dir, `app.log`), MaxSize: 1, MaxBackups: 1},
+		&RotateConfig{Compression: CompressionNone}, nil, func(path string) { archived = append(archived, path) })
+
+	line := []byte(strings.Repeat(`a`, 1023) + "\n")
+	for i := 0; i < 4*1024+1; i++ {
+		_, err := writer.Write(line)
+		require.NoError(t, err)
+
+		// 等待切分后的清理完成，保证每次切分都被通知
+		writer.milling.Wait()
+	}
+
+	require.NoError(t, writer.Close())
+
+	files, err := writer.source.archives()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Greater(t, len(archived), 1)
+	require.Equal(t, map[string]bool{files[0].path: true}, writer.seen)
+}
@@ -0,0 +1,345 @@
+package log2
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// OverflowPolicy 异步缓冲区满时的处理策略
+type OverflowPolicy string
+
+const (
+	// OverflowBlock 阻塞等待缓冲区有空位
+	OverflowBlock = OverflowPolicy(`block`)
+	// OverflowDropNewest 丢弃新写入的日志
+	OverflowDropNewest = OverflowPolicy(`drop-newest`)
+	// OverflowDropOldest 丢弃缓冲区中最早的日志
+	OverflowDropOldest = OverflowPolicy(`drop-oldest`)
+	// OverflowDropBelowLevel 丢弃低于AsyncConfig.DropLevel的日志，其他级别阻塞等待
+	OverflowDropBelowLevel = OverflowPolicy(`drop-below-level`)
+)
+
+const (
+	defaultAsyncBufferSize    = 4096
+	defaultAsyncFlushInterval = 1000
+	// asyncBatchSize 累计超过该字节数立即写入
+	asyncBatchSize = 256 * 1024
+)
+
+// AsyncConfig 异步写入配置
+type AsyncConfig struct {
+	BufferSize     int            `yaml:"bufferSize"`     // 缓冲的日志条数，默认4096
+	FlushInterval  int            `yaml:"flushInterval"`  // 刷新间隔,单位为毫秒，默认1000
+	OverflowPolicy OverflowPolicy `yaml:"overflowPolicy"` // 缓冲区满时的策略,block/drop-newest/drop-oldest/drop-below-level,默认block
+	DropLevel      zapcore.Level  `yaml:"dropLevel"`      // drop-below-level策略下低于该级别的日志被丢弃
+}
+
+func (c *AsyncConfig) tidy() error {
+	if c.BufferSize <= 0 {
+		c.BufferSize = defaultAsyncBufferSize
+	}
+
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaultAsyncFlushInterval
+	}
+
+	switch c.OverflowPolicy {
+	case ``:
+		c.OverflowPolicy = OverflowBlock
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowDropBelowLevel:
+	default:
+		return errors.Errorf(`未知的溢出策略[%s]`, c.OverflowPolicy)
+	}
+
+	return nil
+}
+
+// AsyncStats 异步写入统计
+type AsyncStats struct {
+	Dropped uint64 // 因缓冲区满丢弃的条数
+	Pending int    // 缓冲区中等待写入的条数
+}
+
+/*
+AsyncStatsOf 获取日志器当前输出的异步写入统计
+参数:
+*	logger    	Logger    	通过Config.Build构建的日志器
+返回值:
+*	AsyncStats	AsyncStats	所有异步输出的合计，未开启异步时为空
+*/
+func AsyncStatsOf(logger Logger) AsyncStats {
+	var result AsyncStats
+
+	target := instanceOf(logger)
+	if target == nil {
+		return result
+	}
+
+	for _, writer := range target.output.Load().asyncWriters {
+		result.Dropped += writer.dropped.Load()
+		result.Pending += len(writer.queue)
+	}
+
+	return result
+}
+
+// asyncItem 队列中的一条日志
+type asyncItem struct {
+	level zapcore.Level
+	data  []byte
+}
+
+// asyncWriter 在后台协程中批量写入的writer
+type asyncWriter struct {
+	config  *AsyncConfig
+	syncer  zapcore.WriteSyncer
+	queue   chan asyncItem
+	flushes chan chan struct{} // Sync的刷新请求，与日志分开，drop-oldest丢弃时不会丢掉刷新请求
+	dropped atomic.Uint64
+	lock    sync.RWMutex // 保护closed，关闭后改为同步写入
+	closed  bool
+	stopped chan struct{}
+	buffer  []byte
+	errLock sync.Mutex
+	err     error // 后台写入的错误，Sync时返回
+}
+
+func newAsyncWriter(config *AsyncConfig, syncer zapcore.WriteSyncer) *asyncWriter {
+	result := &asyncWriter{
+		config:  config,
+		syncer:  syncer,
+		queue:   make(chan asyncItem, config.BufferSize),
+		flushes: make(chan chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go result.run()
+
+	return result
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(time.Duration(w.config.FlushInterval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case item, ok := <-w.queue:
+			if !ok {
+				w.flush()
+
+				return
+			}
+
+			w.append(item)
+		case flushed := <-w.flushes:
+			// 请求之前写入的日志都已在队列中
+			for n := len(w.queue); n > 0; n-- {
+				item, ok := <-w.queue
+				if !ok {
+					break
+				}
+
+				w.append(item)
+			}
+
+			w.flush()
+			w.setErr(w.syncer.Sync())
+			close(flushed)
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+func (w *asyncWriter) append(item asyncItem) {
+	w.buffer = append(w.buffer, item.data...)
+	if len(w.buffer) >= asyncBatchSize {
+		w.flush()
+	}
+}
+
+func (w *asyncWriter) flush() {
+	if len(w.buffer) == 0 {
+		return
+	}
+
+	_, err := w.syncer.Write(w.buffer)
+	w.setErr(err)
+	w.buffer = w.buffer[:0]
+}
+
+func (w *asyncWriter) setErr(err error) {
+	if err == nil {
+		return
+	}
+
+	w.errLock.Lock()
+	defer w.errLock.Unlock()
+
+	w.err = err
+}
+
+func (w *asyncWriter) takeErr() error {
+	w.errLock.Lock()
+	defer w.errLock.Unlock()
+
+	err := w.err
+	w.err = nil
+
+	return err
+}
+
+// write 按溢出策略写入队列，已关闭时直接同步写入
+func (w *asyncWriter) write(level zapcore.Level, data []byte) error {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.closed {
+		_, err := w.syncer.Write(data)
+
+		return err
+	}
+
+	item := asyncItem{level: level, data: data}
+
+	switch w.config.OverflowPolicy {
+	case OverflowDropNewest:
+		w.tryEnqueue(item)
+	case OverflowDropOldest:
+		for {
+			select {
+			case w.queue <- item:
+				return nil
+			default:
+			}
+
+			select {
+			case <-w.queue:
+				w.dropped.Add(1)
+			default:
+			}
+		}
+	case OverflowDropBelowLevel:
+		if level < w.config.DropLevel {
+			w.tryEnqueue(item)
+		} else {
+			w.queue <- item
+		}
+	default:
+		w.queue <- item
+	}
+
+	return nil
+}
+
+func (w *asyncWriter) tryEnqueue(item asyncItem) {
+	select {
+	case w.queue <- item:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// Sync 等待此前写入的日志全部写入底层并同步
+func (w *asyncWriter) Sync() error {
+	w.lock.RLock()
+
+	if w.closed {
+		w.lock.RUnlock()
+
+		return w.syncer.Sync()
+	}
+
+	flushed := make(chan struct{})
+	w.flushes <- flushed
+	w.lock.RUnlock()
+
+	<-flushed
+
+	return w.takeErr()
+}
+
+// Close 写完缓冲区中的日志后停止后台协程，之后的写入改为同步
+func (w *asyncWriter) Close() error {
+	w.lock.Lock()
+
+	if w.closed {
+		w.lock.Unlock()
+
+		return nil
+	}
+
+	w.closed = true
+	close(w.queue)
+	w.lock.Unlock()
+
+	<-w.stopped
+
+	return errors.Wrap(multierr.Append(w.takeErr(), w.syncer.Sync()), `关闭异步写入`)
+}
+
+// asyncCore 编码后交给asyncWriter写入的core
+type asyncCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	writer  *asyncWriter
+}
+
+func newAsyncCore(encoder zapcore.Encoder, writer *asyncWriter, enabler zapcore.LevelEnabler) zapcore.Core {
+	return &asyncCore{LevelEnabler: enabler, encoder: encoder, writer: writer}
+}
+
+func (c *asyncCore) Level() zapcore.Level {
+	return zapcore.LevelOf(c.LevelEnabler)
+}
+
+func (c *asyncCore) With(fields []zapcore.Field) zapcore.Core {
+	encoder := c.encoder.Clone()
+	for i := range fields {
+		fields[i].AddTo(encoder)
+	}
+
+	return &asyncCore{LevelEnabler: c.LevelEnabler, encoder: encoder, writer: c.writer}
+}
+
+func (c *asyncCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c *asyncCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buffer, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return errors.Wrap(err, `编码`)
+	}
+
+	data := make([]byte, buffer.Len())
+	copy(data, buffer.Bytes())
+	buffer.Free()
+
+	if err = c.writer.write(entry.Level, data); err != nil {
+		return errors.Wrap(err, `写入`)
+	}
+
+	// 与zap一致，高于Error的日志之后进程可能退出，需要立即同步
+	if entry.Level > zapcore.ErrorLevel {
+		return c.Sync()
+	}
+
+	return nil
+}
+
+func (c *asyncCore) Sync() error {
+	return c.writer.Sync()
+}
@@ -0,0 +1,147 @@
+package log2
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TestAsyncConfig_tidy 测试异步配置默认值及校验
+func TestAsyncConfig_tidy(t *testing.T) {
+	cfg := &AsyncConfig{}
+	require.NoError(t, cfg.tidy())
+	require.Equal(t, defaultAsyncBufferSize, cfg.BufferSize)
+	require.Equal(t, defaultAsyncFlushInterval, cfg.FlushInterval)
+	require.Equal(t, OverflowBlock, cfg.OverflowPolicy)
+
+	require.Error(t, (&AsyncConfig{OverflowPolicy: `unknown`}).tidy())
+
+	config, err := NewConfigFromToml([]byte("[Async]\nBufferSize = 10\nOverflowPolicy = 'drop-oldest'\n"))
+	require.NoError(t, err)
+	require.Equal(t, OverflowDropOldest, config.Async.OverflowPolicy)
+}
+
+// TestAsyncWriter_Overflow 测试缓冲区满时的各个策略
+func TestAsyncWriter_Overflow(t *testing.T) {
+	tests := []struct {
+		policy  OverflowPolicy
+		dropped uint64
+		want    string
+	}{
+		{OverflowDropNewest, 2, `12`},
+		{OverflowDropOldest, 2, `34`},
+		{OverflowDropBelowLevel, 1, `124`},
+	}
+
+	levels := []zapcore.Level{zapcore.InfoLevel, zapcore.InfoLevel, zapcore.InfoLevel, zapcore.ErrorLevel}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			hook := &bufferHook{}
+			config := &AsyncConfig{BufferSize: 2, OverflowPolicy: tt.policy, DropLevel: zapcore.WarnLevel}
+			require.NoError(t, config.tidy())
+
+			// 后台协程未启动，模拟写入缓慢时缓冲区满
+			writer := &asyncWriter{
+				config:  config,
+				syncer:  zapcore.AddSync(hook),
+				queue:   make(chan asyncItem, config.BufferSize),
+				flushes: make(chan chan struct{}),
+				stopped: make(chan struct{}),
+			}
+
+			started := false
+
+			for i, level := range levels {
+				if tt.policy == OverflowDropBelowLevel && level >= config.DropLevel {
+					// 不低于DropLevel的日志阻塞等待，需要启动后台协程
+					go writer.run()
+
+					started = true
+				}
+
+				require.NoError(t, writer.write(level, []byte(strconv.Itoa(i+1))))
+			}
+
+			if !started {
+				go writer.run()
+			}
+
+			require.NoError(t, writer.Close())
+			require.Equal(t, tt.dropped, writer.dropped.Load())
+			require.Equal(t, tt.want, hook.String())
+		})
+	}
+}
+
+// TestAsyncWriter_SyncWhenFull 测试缓冲区满时Sync，drop-oldest不会丢掉刷新请求
+func TestAsyncWriter_SyncWhenFull(t *testing.T) {
+	hook := &bufferHook{}
+	config := &AsyncConfig{BufferSize: 2, OverflowPolicy: OverflowDropOldest}
+	require.NoError(t, config.tidy())
+
+	writer := &asyncWriter{
+		config:  config,
+		syncer:  zapcore.AddSync(hook),
+		queue:   make(chan asyncItem, config.BufferSize),
+		flushes: make(chan chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	require.NoError(t, writer.write(zapcore.InfoLevel, []byte(`1`)))
+
+	synced := make(chan error, 1)
+
+	go func() {
+		synced <- writer.Sync()
+	}()
+
+	for i := 2; i <= 4; i++ {
+		require.NoError(t, writer.write(zapcore.InfoLevel, []byte(strconv.Itoa(i))))
+	}
+
+	go writer.run()
+
+	select {
+	case err := <-synced:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, `Sync未返回`)
+	}
+
+	require.Equal(t, `34`, hook.String(), `Sync之前写入的日志都已写入`)
+	require.NoError(t, writer.Close())
+}
+
+// TestConfig_BuildAsync 测试开启异步写入后Sync写完所有日志
+func TestConfig_BuildAsync(t *testing.T) {
+	hook := &bufferHook{minLevel: zapcore.DebugLevel}
+	cfg := &Config{
+		Service:     "test",
+		HideConsole: true,
+		Hooks:       []Hook{hook},
+		Async:       &AsyncConfig{FlushInterval: 60000},
+	}
+
+	root, err := cfg.Build()
+	require.NoError(t, err)
+
+	derived := root.Derive(`async`).With(zap.String(`key`, `value`))
+	for i := 0; i < 100; i++ {
+		derived.Info(`异步`)
+	}
+
+	require.NoError(t, instanceOf(root).output.Load().core.Sync())
+	require.Equal(t, 100, strings.Count(hook.String(), `异步`))
+	require.Equal(t, AsyncStats{}, AsyncStatsOf(root))
+
+	// 关闭后改为同步写入
+	require.NoError(t, instanceOf(root).output.Load().close())
+	derived.Info(`关闭后`)
+	require.Contains(t, hook.String(), `关闭后`)
+}
@@ -0,0 +1,288 @@
+package log2
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// CompressionType 切分出的文件的压缩方式
+type CompressionType string
+
+const (
+	// CompressionNone 不压缩
+	CompressionNone = CompressionType(`none`)
+	// CompressionGzip gzip压缩，扩展名为.gz
+	CompressionGzip = CompressionType(`gzip`)
+	// CompressionZstd zstd压缩，扩展名为.zst
+	CompressionZstd = CompressionType(`zstd`)
+)
+
+const (
+	// compressExt gzip压缩后追加的扩展名
+	compressExt = `.gz`
+	// zstdExt zstd压缩后追加的扩展名
+	zstdExt = `.zst`
+	// defaultCompressWorkers 默认的压缩协程数
+	defaultCompressWorkers = 1
+	// compressQueueSize 等待压缩的文件数，队列满时提交方等待
+	compressQueueSize = 64
+)
+
+var (
+	// compressedExts 所有压缩方式的扩展名
+	compressedExts = []string{compressExt, zstdExt}
+	// compressedSuffix 匹配可选的压缩扩展名
+	compressedSuffix = `(?:` + regexp.QuoteMeta(compressExt) + `|` + regexp.QuoteMeta(zstdExt) + `)?`
+)
+
+/*
+compression 实际使用的压缩方式
+返回值:
+*	CompressionType	CompressionType	未配置Compression时按DisableCompress为none或者gzip
+*/
+func (c *RotateConfig) compression() CompressionType {
+	if c.Compression != `` {
+		return c.Compression
+	}
+
+	if c.DisableCompress {
+		return CompressionNone
+	}
+
+	return CompressionGzip
+}
+
+// checkCompression 检查压缩方式及级别
+func (c *RotateConfig) checkCompression() error {
+	switch c.compression() {
+	case CompressionNone:
+	case CompressionGzip:
+		if c.CompressionLevel != 0 && (c.CompressionLevel < gzip.BestSpeed || c.CompressionLevel > gzip.BestCompression) {
+			return errors.Errorf(`gzip压缩级别[%d]需要在%d到%d之间`, c.CompressionLevel, gzip.BestSpeed, gzip.BestCompression)
+		}
+	case CompressionZstd:
+		if c.CompressionLevel < 0 || c.CompressionLevel > 22 {
+			return errors.Errorf(`zstd压缩级别[%d]需要在1到22之间`, c.CompressionLevel)
+		}
+	default:
+		return errors.Errorf(`未知的压缩方式[%s]`, c.Compression)
+	}
+
+	return nil
+}
+
+// extOf 压缩方式对应的扩展名
+func extOf(codec CompressionType) string {
+	switch codec {
+	case CompressionGzip:
+		return compressExt
+	case CompressionZstd:
+		return zstdExt
+	default:
+		return ``
+	}
+}
+
+// compressedExtOf 文件的压缩扩展名，未压缩时为空
+func compressedExtOf(path string) string {
+	for _, ext := range compressedExts {
+		if strings.HasSuffix(path, ext) {
+			return ext
+		}
+	}
+
+	return ``
+}
+
+// compressedPath 已存在的压缩后的文件，不存在时为空
+func compressedPath(path string) string {
+	for _, ext := range compressedExts {
+		if _, err := os.Stat(path + ext); err == nil {
+			return path + ext
+		}
+	}
+
+	return ``
+}
+
+// compressJob 一个压缩任务
+type compressJob struct {
+	source string
+	codec  CompressionType
+	level  int
+	done   chan compressResult
+}
+
+type compressResult struct {
+	target string
+	err    error
+}
+
+// compressor 固定数量的协程在后台压缩，同一个输出的所有文件共用，避免同时切分时占用过多CPU
+type compressor struct {
+	jobs    chan compressJob
+	lock    sync.RWMutex // 保护closed，关闭后改为在调用方压缩
+	closed  bool
+	workers sync.WaitGroup
+	busy    *busyFiles // 压缩期间标记源文件及压缩文件
+}
+
+func newCompressor(workers int) *compressor {
+	if workers <= 0 {
+		workers = defaultCompressWorkers
+	}
+
+	result := &compressor{jobs: make(chan compressJob, compressQueueSize)}
+
+	for i := 0; i < workers; i++ {
+		result.workers.Add(1)
+
+		go func() {
+			defer result.workers.Done()
+
+			for job := range result.jobs {
+				target, err := compressFile(job.source, job.codec, job.level)
+				job.done <- compressResult{target: target, err: err}
+			}
+		}()
+	}
+
+	return result
+}
+
+/*
+compress 压缩文件并等待完成，成功后删除源文件，在切分后的后台协程中调用
+compressor为空或者已关闭时直接在调用方压缩
+参数:
+*	source	string         	源文件
+*	codec 	CompressionType	压缩方式
+*	level 	int            	级别，0为默认
+返回值:
+*	string	string         	压缩后的文件
+*	error 	error          	错误
+*/
+func (c *compressor) compress(source string, codec CompressionType, level int) (string, error) {
+	if c == nil {
+		return compressFile(source, codec, level)
+	}
+
+	target := source + extOf(codec)
+	c.busy.add(source, target)
+	defer c.busy.done(source, target)
+
+	c.lock.RLock()
+
+	if c.closed {
+		c.lock.RUnlock()
+
+		return compressFile(source, codec, level)
+	}
+
+	done := make(chan compressResult, 1)
+	c.jobs <- compressJob{source: source, codec: codec, level: level, done: done}
+	c.lock.RUnlock()
+
+	result := <-done
+
+	return result.target, result.err
+}
+
+// Close 等待已提交的任务完成后停止协程
+func (c *compressor) Close() error {
+	c.lock.Lock()
+
+	if c.closed {
+		c.lock.Unlock()
+
+		return nil
+	}
+
+	c.closed = true
+	close(c.jobs)
+	c.lock.Unlock()
+
+	c.workers.Wait()
+
+	return nil
+}
+
+// compressFile 压缩文件，成功后删除源文件，失败时删除不完整的压缩文件
+func compressFile(source string, codec CompressionType, level int) (target string, err error) {
+	target = source + extOf(codec)
+
+	reader, err := os.Open(source)
+	if err != nil {
+		return ``, errors.Wrapf(err, `打开日志文件[%s]`, source)
+	}
+	defer reader.Close()
+
+	info, err := reader.Stat()
+	if err != nil {
+		return ``, errors.Wrapf(err, `获取日志文件[%s]信息`, source)
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return ``, errors.Wrapf(err, `创建压缩文件[%s]`, target)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = os.Remove(target)
+		}
+	}()
+
+	writer, err := newCompressWriter(file, codec, level)
+	if err != nil {
+		_ = file.Close()
+
+		return ``, err
+	}
+
+	if _, err = io.Copy(writer, reader); err != nil {
+		_ = file.Close()
+
+		return ``, errors.Wrapf(err, `压缩日志文件[%s]`, source)
+	}
+
+	if err = multierr.Combine(writer.Close(), file.Close()); err != nil {
+		return ``, errors.Wrapf(err, `写入压缩文件[%s]`, target)
+	}
+
+	_ = os.Chtimes(target, info.ModTime(), info.ModTime())
+	_ = reader.Close()
+
+	return target, errors.Wrapf(os.Remove(source), `删除日志文件[%s]`, source)
+}
+
+func newCompressWriter(writer io.Writer, codec CompressionType, level int) (io.WriteCloser, error) {
+	switch codec {
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+
+		result, err := gzip.NewWriterLevel(writer, level)
+
+		return result, errors.Wrap(err, `gzip`)
+	case CompressionZstd:
+		options := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+
+		result, err := zstd.NewWriter(writer, options...)
+
+		return result, errors.Wrap(err, `zstd`)
+	default:
+		return nil, errors.Errorf(`未知的压缩方式[%s]`, codec)
+	}
+}
@@ -0,0 +1,174 @@
+package log2
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+// readCompressed 按扩展名解压文件
+func readCompressed(t *testing.T, path string) string {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	var reader io.Reader
+
+	switch compressedExtOf(path) {
+	case compressExt:
+		reader, err = gzip.NewReader(file)
+		require.NoError(t, err)
+	case zstdExt:
+		decoder, err := zstd.NewReader(file)
+		require.NoError(t, err)
+
+		defer decoder.Close()
+
+		reader = decoder
+	default:
+		reader = file
+	}
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+// TestCompressFile 测试gzip及zstd压缩
+func TestCompressFile(t *testing.T) {
+	content := strings.Repeat("日志内容\n", 1000)
+
+	for _, test := range []struct {
+		codec CompressionType
+		level int
+		ext   string
+	}{
+		{CompressionGzip, 0, `.gz`},
+		{CompressionGzip, 9, `.gz`},
+		{CompressionZstd, 0, `.zst`},
+		{CompressionZstd, 19, `.zst`},
+	} {
+		source := filepath.Join(t.TempDir(), `app.log`)
+		require.NoError(t, os.WriteFile(source, []byte(content), 0o644))
+
+		target, err := compressFile(source, test.codec, test.level)
+		require.NoError(t, err)
+		require.Equal(t, source+test.ext, target)
+		require.NoFileExists(t, source)
+		require.Equal(t, content, readCompressed(t, target))
+	}
+}
+
+// TestRotateConfig_Compression 测试压缩方式的默认值及检查
+func TestRotateConfig_Compression(t *testing.T) {
+	require.Equal(t, CompressionGzip, (&RotateConfig{}).compression())
+	require.Equal(t, CompressionNone, (&RotateConfig{DisableCompress: true}).compression())
+	require.Equal(t, CompressionZstd, (&RotateConfig{DisableCompress: true, Compression: CompressionZstd}).compression(), `Compression优先`)
+
+	require.NoError(t, (&RotateConfig{Compression: CompressionZstd, CompressionLevel: 22}).check(false))
+	require.Error(t, (&RotateConfig{Compression: CompressionGzip, CompressionLevel: 10}).check(false))
+	require.Error(t, (&RotateConfig{Compression: CompressionZstd, CompressionLevel: 23}).check(false))
+	require.Error(t, (&RotateConfig{Compression: `lz4`}).check(false))
+}
+
+// TestCompressor 测试后台压缩，关闭后改为在调用方压缩
+func TestCompressor(t *testing.T) {
+	dir := t.TempDir()
+	pool := newCompressor(2)
+
+	results := make(chan string, 8)
+	for i := 0; i < 8; i++ {
+		source := filepath.Join(dir, `app-`+string(rune('a'+i))+`.log`)
+		require.NoError(t, os.WriteFile(source, []byte(`content`), 0o644))
+
+		go func() {
+			target, err := pool.compress(source, CompressionZstd, 0)
+			require.NoError(t, err)
+			results <- target
+		}()
+	}
+
+	for i := 0; i < 8; i++ {
+		require.Equal(t, `content`, readCompressed(t, <-results))
+	}
+
+	require.NoError(t, pool.Close())
+	require.NoError(t, pool.Close())
+
+	source := filepath.Join(dir, `closed.log`)
+	require.NoError(t, os.WriteFile(source, []byte(`content`), 0o644))
+
+	target, err := pool.compress(source, CompressionGzip, 0)
+	require.NoError(t, err)
+	require.Equal(t, `content`, readCompressed(t, target))
+}
+
+// TestConfig_BuildCompression 测试主文件及LevelToPath的文件使用相同的压缩方式
+func TestConfig_BuildCompression(t *testing.T) {
+	dir := t.TempDir()
+	rotated := make(chan string, 10)
+
+	root, err := (&Config{
+		Service:     `test`,
+		FilePath:    filepath.Join(dir, `app`),
+		HideConsole: true,
+		LevelToPath: map[string]string{`error`: filepath.Join(dir, `error.log`)},
+		Rotate: &RotateConfig{
+			MaxSize:     1,
+			Compression: CompressionZstd,
+			OnRotate:    func(path string) { rotated <- path },
+		},
+	}).Build()
+	require.NoError(t, err)
+
+	message := strings.Repeat(`a`, 1024)
+	for i := 0; i < 1100; i++ {
+		root.Info(message)
+		root.Error(message)
+	}
+
+	require.NoError(t, root.Close())
+	close(rotated)
+
+	var archived []string
+	for path := range rotated {
+		require.True(t, strings.HasSuffix(path, `.log.zst`), path)
+		require.Contains(t, readCompressed(t, path), message)
+
+		archived = append(archived, filepath.Base(path))
+	}
+
+	require.Len(t, archived, 2)
+	require.True(t, strings.HasPrefix(archived[0], `app-`) || strings.HasPrefix(archived[1], `app-`), archived)
+	require.True(t, strings.HasPrefix(archived[0], `error-`) || strings.HasPrefix(archived[1], `error-`), archived)
+}
+
+// TestRotateWriter_Zstd 测试按时间切分的文件使用zstd压缩
+func TestRotateWriter_Zstd(t *testing.T) {
+	dir := t.TempDir()
+	now := &clock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	writer := newRotateWriter(filepath.Join(dir, `app.log`), `test`, &RotateConfig{Policy: RotateByTime, Compression: CompressionZstd}, time.UTC)
+	writer.now = now.Now
+	writer.compressor = newCompressor(1)
+
+	_, err := writer.Write([]byte("first\n"))
+	require.NoError(t, err)
+
+	now.now = now.now.AddDate(0, 0, 1)
+	_, err = writer.Write([]byte("second\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, writer.compressor.Close())
+
+	require.Equal(t, "first\n", readCompressed(t, filepath.Join(dir, `app-2024-01-02.log.zst`)))
+}
@@ -3,6 +3,7 @@ package log2
 import (
 	"io"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/pelletier/go-toml/v2"
@@ -24,38 +25,60 @@ const (
 	defaultRotateMaxAge     = 7
 )
 
-var (
-	core          zapcore.Core
-	encoder       zapcore.Encoder
-	writeSyncer   zapcore.WriteSyncer
-	inputCores    []zapcore.Core
-	HiddenConsole bool
-)
-
 // RotateConfig rotate 配置
 type RotateConfig struct {
 	MaxSize         int  `yaml:"maxSize"`         // 单个日志文件最大大小，单位为MB
 	MaxBackups      int  `yaml:"maxBackups"`      // 最大部分数量
 	MaxAge          int  `yaml:"maxAge"`          // 最大保留时间,单位为天
-	DisableCompress bool `yaml:"disableCompress"` // 不压缩
+	DisableCompress bool `yaml:"disableCompress"` // 不压缩，配置了Compression时忽略
+	// Compression 压缩方式,none/gzip/zstd，默认按DisableCompress为none或者gzip，主文件及LevelToPath的文件一致
+	Compression      CompressionType `yaml:"compression"`
+	CompressionLevel int             `yaml:"compressionLevel"` // 压缩级别，gzip为1-9，zstd为1-22，0为默认
+	CompressWorkers  int             `yaml:"compressWorkers"`  // 后台压缩的协程数，默认1
+	// Policy 切分策略,size/time/time-or-size，默认size
+	Policy RotatePolicy `yaml:"policy"`
+	// Interval 按时间切分的周期,daily/hourly，默认daily，按Config.TimeZone对齐
+	Interval RotateInterval `yaml:"interval"`
+	// Pattern 文件名模板，支持{service}、{name}、{date}、{index}，如{service}-{date}-{index}.log，
+	// 配置后Config.FilePath+.log及LevelToPath中的路径成为指向当前文件的符号链接
+	Pattern        string `yaml:"pattern"`
+	DisableSymlink bool   `yaml:"disableSymlink"` // 不创建指向当前文件的符号链接
+	// OnRotate 切分出的文件完成(开启压缩时为压缩完成)后在后台调用，参数为切分出的文件路径
+	OnRotate func(archivedPath string) `yaml:"-"`
+	Upload   *UploadConfig             `yaml:"upload"` // 切分出的文件上传到S3兼容的对象存储，为空时不上传
 }
 
 // Config 日志器配置
 type Config struct {
-	Rotate      *RotateConfig `yaml:"rotate"`
-	levelToPath map[zapcore.Level]string
-	LevelToPath map[string]string `yaml:"levelToPath"`
-	location    *time.Location    `yaml:"location"`
-	TimeZone    string            `yaml:"timeZone"`
-	TimeLayout  string            `yaml:"timeLayout"`
-	Service     string            `yaml:"service"`
-	FilePath    string            `yaml:"filePath"`
-	Hooks       []Hook
-	Debug       bool          `yaml:"debug"`
-	Dev         bool          `yaml:"dev"`
-	JSON        bool          `yaml:"json"`
-	HideConsole bool          `yaml:"hideConsole"`
-	Level       zapcore.Level `yaml:"level"`
+	Rotate        *RotateConfig     `yaml:"rotate"`
+	Retention     *RetentionConfig  `yaml:"retention"`     // 所有日志文件合计的磁盘配额，为空时不限制
+	Async         *AsyncConfig      `yaml:"async"`         // 异步写入，为空时同步写入
+	Sampling      *SamplingConfig   `yaml:"sampling"`      // 采样，为空时不采样
+	RateLimit     *RateLimitConfig  `yaml:"rateLimit"`     // 按消息限流，为空时不限流
+	Dedupe        *DedupeConfig     `yaml:"dedupe"`        // 窗口内相同日志去重，为空时不去重
+	Redact        *RedactConfig     `yaml:"redact"`        // 敏感信息脱敏，为空时不脱敏
+	Trace         *TraceConfig      `yaml:"trace"`         // OpenTelemetry链路关联，为空时不关联
+	OTLP          *OTLPConfig       `yaml:"otlp"`          // 通过OTLP导出到采集器，为空时不导出
+	TaskID        *TaskIDConfig     `yaml:"taskID"`        // Start生成任务ID的方式及字段名，为空时使用ObjectID
+	EncoderPreset string            `yaml:"encoderPreset"` // 编码器预设,classic/ecs/gcp-cloud-logging/datadog/loki，默认classic
+	FieldPreset   string            `yaml:"fieldPreset"`   // 内置字段的名称方案,zh/en/ecs/otel，默认zh，EncoderPreset为ecs时默认ecs
+	FieldNames    map[string]string `yaml:"fieldNames"`    // 覆盖方案中的字段名，键为Field开头的常量，如taskID
+	fieldNames    map[string]string
+	levelToPath   map[zapcore.Level]string
+	LevelToPath   map[string]string `yaml:"levelToPath"`
+	Routes        []RouteConfig     `yaml:"routes"` // 按级别、日志器名称及字段写入单独的文件，与LevelToPath互不影响
+	location      *time.Location    `yaml:"location"`
+	TimeZone      string            `yaml:"timeZone"`
+	TimeLayout    string            `yaml:"timeLayout"`
+	Service       string            `yaml:"service"`
+	FilePath      string            `yaml:"filePath"`
+	Hooks         []Hook
+	Debug         bool          `yaml:"debug"`
+	Dev           bool          `yaml:"dev"`
+	JSON          bool          `yaml:"json"`
+	Format        string        `yaml:"format"` // 输出格式,console/json/logfmt，为空时由JSON决定
+	HideConsole   bool          `yaml:"hideConsole"`
+	Level         zapcore.Level `yaml:"level"`
 }
 
 /*
@@ -84,6 +107,71 @@ func (l *Config) tidy() error {
 		l.levelToPath[level] = path
 	}
 
+	if l.Async != nil {
+		if err = l.Async.tidy(); err != nil {
+			return errors.Wrap(err, `异步写入配置`)
+		}
+	}
+
+	if l.Sampling != nil {
+		if err = l.Sampling.tidy(); err != nil {
+			return errors.Wrap(err, `采样配置`)
+		}
+	}
+
+	if l.RateLimit != nil {
+		l.RateLimit.tidy()
+	}
+
+	if l.Dedupe != nil {
+		l.Dedupe.tidy()
+	}
+
+	if l.Retention != nil {
+		l.Retention.tidy()
+	}
+
+	if l.Redact != nil {
+		if err = l.Redact.tidy(); err != nil {
+			return errors.Wrap(err, `脱敏配置`)
+		}
+	}
+
+	if l.Rotate != nil {
+		if err = l.Rotate.check(len(l.levelToPath) > 0 || len(l.Routes) > 0); err != nil {
+			return errors.Wrap(err, `切分配置`)
+		}
+	}
+
+	if err = l.tidyRoutes(); err != nil {
+		return err
+	}
+
+	switch l.Format {
+	case ``, FormatConsole, FormatJSON, FormatLogfmt:
+	default:
+		return errors.Errorf(`未知的输出格式[%s]`, l.Format)
+	}
+
+	if err = checkEncoderPreset(l.EncoderPreset); err != nil {
+		return err
+	}
+
+	fieldPreset := l.FieldPreset
+	if fieldPreset == `` && l.EncoderPreset == EncoderECS {
+		fieldPreset = FieldPresetECS
+	}
+
+	if l.fieldNames, err = resolveFieldNames(fieldPreset, l.FieldNames); err != nil {
+		return errors.Wrap(err, `字段名配置`)
+	}
+
+	if l.TaskID != nil {
+		if err = l.TaskID.tidy(l.fieldNames[FieldTaskID]); err != nil {
+			return errors.Wrap(err, `任务ID配置`)
+		}
+	}
+
 	return nil
 }
 
@@ -131,15 +219,51 @@ Build 构建日志器
 func (l *Config) Build(cores ...zapcore.Core) (logger Logger, err error) {
 	var (
 		underlyingLogger *zap.Logger
-		allCores         []zapcore.Core
+		out              *output
+		target           = &instance{
+			config:     l,
+			inputCores: cores,
+			levels:     newLevelRegistry(l.Level),
+		}
+	)
+
+	if out, err = l.buildOutput(cores...); err != nil {
+		return nil, err
+	}
+
+	target.output.Store(out)
+	out.startUploads(nil)
+
+	// 级别统一在最外层控制，调整级别时无需重建core；输出通过dynamicCore间接访问，重新加载配置时无需重建日志器
+	underlyingLogger = zap.New(newLevelCore(newDynamicCore(target), target.levels), zap.AddCaller())
+
+	result := NewLogger(underlyingLogger.With(zap.String(l.fieldNames[FieldService], l.Service)), ``, 1, true, false, l.levelToPath, nil)
+	result.instance = target
+
+	return result, nil
+}
+
+/*
+buildOutput 按配置构建所有输出
+参数:
+*	cores  	...zapcore.Core	额外的core
+返回值:
+*	out    	*output        	输出
+*	err    	error          	错误
+*/
+func (l *Config) buildOutput(cores ...zapcore.Core) (out *output, err error) {
+	var (
+		allCores []zapcore.Core
 	)
 
 	if err = l.tidy(); err != nil {
 		return nil, errors.Wrap(err, `tidy`)
 	}
 
-	HiddenConsole = l.HideConsole
-	inputCores = cores
+	out = &output{trace: l.Trace, fieldNames: l.fieldNames, taskID: l.TaskID}
+	if out.taskID == nil {
+		out.taskID = &TaskIDConfig{Key: l.fieldNames[FieldTaskID], Generator: IDObjectID, newID: newObjectID}
+	}
 
 	cfg := &zap.Config{
 		Level:            zap.NewAtomicLevelAt(l.Level),
@@ -169,71 +293,132 @@ func (l *Config) Build(cores ...zapcore.Core) (logger Logger, err error) {
 	// todo: 如何验证一个time layout 是否正确
 
 	cfg.EncoderConfig = l.newEncoderConfig()
+	out.encoder = newEncoder(l.format(), cfg.EncoderConfig, l.EncoderPreset)
 
-	if l.JSON {
-		encoder = zapcore.NewJSONEncoder(cfg.EncoderConfig)
-	} else {
-		encoder = zapcore.NewConsoleEncoder(cfg.EncoderConfig)
+	if l.Rotate == nil {
+		l.Rotate = &RotateConfig{}
 	}
 
-	if l.FilePath != `` {
-		if l.Rotate == nil {
-			l.Rotate = &RotateConfig{}
-		}
+	out.busy = newBusyFiles()
+	files := &fileBuilder{config: l, out: out}
 
-		lumberjackLogger := &lumberjack.Logger{
-			Filename:   l.FilePath + ".log",
-			MaxSize:    l.Rotate.MaxSize, // megabytes
-			MaxBackups: l.Rotate.MaxBackups,
-			MaxAge:     l.Rotate.MaxAge, // days
-			Compress:   !l.Rotate.DisableCompress,
+	if l.FilePath != `` {
+		syncer, err := files.syncer(l.FilePath+rotateExt, l.Rotate, l.logDir())
+		if err != nil {
+			return nil, err
 		}
 
-		fillLumberjack(lumberjackLogger)
-
-		writeSyncer = zapcore.NewMultiWriteSyncer(zapcore.AddSync(lumberjackLogger))
-
-		allCores = append(allCores, zapcore.NewCore(
-			encoder,
-			writeSyncer,
-			newLevelEnablerWithExcept(cfg.Level, l.levelToPath),
+		allCores = append(allCores, newMovedCore(
+			l.newCore(out, out.encoder, syncer, newLevelEnablerWithExcept(anyLevel, l.levelToPath)),
+			l.movedMatchers(),
 		))
 	}
 
 	if l.levelToPath != nil {
 		for level := range l.levelToPath {
-			lumberjackLogger := &lumberjack.Logger{
-				Filename:   l.levelToPath[level],
-				MaxSize:    l.Rotate.MaxSize, // megabytes
-				MaxBackups: l.Rotate.MaxBackups,
-				MaxAge:     l.Rotate.MaxAge, // days
-				Compress:   true,
+			syncer, err := files.syncer(l.levelToPath[level], l.Rotate, l.logDir())
+			if err != nil {
+				return nil, err
 			}
 
-			fillLumberjack(lumberjackLogger)
+			allCores = append(allCores, l.newCore(out, out.encoder, syncer, newLevelEnablerWithExcept(level, l.levelToPath, level)))
+		}
+	}
+
+	for i := range l.Routes {
+		route := &l.Routes[i]
 
-			writeSyncer = zapcore.NewMultiWriteSyncer(zapcore.AddSync(lumberjackLogger))
+		rotate, dir := l.Rotate, l.logDir()
+		if route.Rotate != nil {
+			rotate, dir = route.Rotate, filepath.Dir(route.Path)
+		}
 
-			allCores = append(allCores, zapcore.NewCore(encoder, writeSyncer, newLevelEnablerWithExcept(level, l.levelToPath, level)))
+		syncer, err := files.syncer(route.Path, rotate, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, `路由[%d]`, i)
 		}
+
+		allCores = append(allCores, newRouteCore(l.newCore(out, l.routeEncoder(route), syncer, anyLevel), route.matcher))
 	}
 
 	if !l.HideConsole {
-		allCores = append(allCores, zapcore.NewCore(encoder, os.Stdout, cfg.Level))
+		allCores = append(allCores, l.newCore(out, out.encoder, consoleSyncer{Writer: os.Stdout}, anyLevel))
 	}
 
 	for i := range l.Hooks {
 		hook := l.Hooks[i]
 
-		allCores = append(allCores, zapcore.NewCore(encoder, zapcore.AddSync(hook.Writer()), hook.MinLevel()))
+		allCores = append(allCores, l.newCore(out, out.encoder, zapcore.AddSync(hook.Writer()), hook.MinLevel()))
+	}
+
+	if l.OTLP != nil {
+		otlpCore, err := NewOTLPCore(l.OTLP, l.Service)
+		if err != nil {
+			return nil, err
+		}
+
+		otlpCore.traceKey, otlpCore.spanKey = l.fieldNames[FieldTraceID], l.fieldNames[FieldSpanID]
+		out.closers = append(out.closers, otlpCore)
+		allCores = append(allCores, otlpCore)
 	}
 
 	allCores = append(allCores, cores...)
 
-	core = zapcore.NewTee(allCores...)
-	underlyingLogger = zap.New(core, zap.AddCaller())
+	out.core = zapcore.NewTee(allCores...)
+
+	if l.Redact != nil {
+		out.core = redactCore{Core: out.core, config: l.Redact}
+	}
+
+	if l.Dedupe != nil {
+		out.core = newDedupeCore(out.core, time.Duration(l.Dedupe.Window)*time.Millisecond, l.fieldNames)
+	}
+
+	if l.Sampling != nil {
+		out.core = newSamplingCore(out.core, l.Sampling)
+	}
+
+	if l.RateLimit != nil {
+		limiter := newRateLimiter(out.core.With([]zapcore.Field{zap.String(l.fieldNames[FieldService], l.Service)}), l.RateLimit, l.fieldNames)
+		out.closers = append(out.closers, limiter)
+		out.core = rateLimitCore{Core: out.core, limiter: limiter}
+	}
+
+	if l.Retention != nil && len(out.files) > 0 {
+		retentionLogger := zap.New(out.core).Named(retentionName).With(zap.String(l.fieldNames[FieldService], l.Service))
+		manager := newRetention(l.Retention, out.files, retentionLogger, l.fieldNames)
+		manager.busy = out.busy
+		out.closers = append(out.closers, manager)
+		manager.start()
+	}
+
+	// 上传由调用方在旧输出关闭后启动
+	files.logger = zap.New(out.core).Named(uploadName).With(zap.String(l.fieldNames[FieldService], l.Service))
+	out.uploads = files
+
+	return out, nil
+}
+
+/*
+newCore 新建写入syncer的core，开启异步写入时由后台协程批量写入
+参数:
+*	out    	*output             	输出，异步writer会记录到其中以便同步和关闭
+*	encoder	zapcore.Encoder     	编码器
+*	syncer 	zapcore.WriteSyncer 	写入目标
+*	enabler	zapcore.LevelEnabler	级别
+返回值:
+*	zapcore.Core	zapcore.Core	core
+*/
+func (l *Config) newCore(out *output, encoder zapcore.Encoder, syncer zapcore.WriteSyncer, enabler zapcore.LevelEnabler) zapcore.Core {
+	if l.Async == nil {
+		return zapcore.NewCore(encoder, syncer, enabler)
+	}
+
+	writer := newAsyncWriter(l.Async, syncer)
+	out.asyncWriters = append(out.asyncWriters, writer)
+	out.closers = append(out.closers, writer)
 
-	return NewLogger(underlyingLogger.With(zap.String(`系统`, l.Service)), ``, 1, true, false, l.levelToPath, nil), nil
+	return newAsyncCore(encoder, writer, enabler)
 }
 
 func NewEasyLogger(debug, hideConsole bool, filePath, service string) (Logger, error) {
@@ -275,9 +460,201 @@ func (l *Config) newEncoderConfig() zapcore.EncoderConfig {
 		config.EncodeCaller = zapcore.FullCallerEncoder
 	}
 
+	// 只有console格式带颜色，json及logfmt由采集端解析
+	if l.format() != FormatConsole {
+		config.EncodeLevel = zapcore.CapitalLevelEncoder
+	}
+
+	l.applyEncoderPreset(&config, l.EncoderPreset, l.Dev)
+
 	return config
 }
 
+/*
+newEncoder 按输出格式新建编码器
+参数:
+*	format 	string               	输出格式
+*	config 	zapcore.EncoderConfig	编码器配置
+*	preset 	string               	编码器预设
+返回值:
+*	zapcore.Encoder	zapcore.Encoder	编码器
+*/
+func newEncoder(format string, config zapcore.EncoderConfig, preset string) zapcore.Encoder {
+	var encoder zapcore.Encoder
+
+	switch format {
+	case FormatLogfmt:
+		encoder = NewLogfmtEncoder(config)
+	case FormatJSON:
+		encoder = zapcore.NewJSONEncoder(config)
+	default:
+		encoder = zapcore.NewConsoleEncoder(config)
+	}
+
+	return wrapEncoderPreset(encoder, preset)
+}
+
+// format 实际使用的输出格式，Format为空时由JSON决定
+func (l *Config) format() string {
+	if l.Format != `` {
+		return l.Format
+	}
+
+	if l.JSON {
+		return FormatJSON
+	}
+
+	return FormatConsole
+}
+
+/*
+newFileSyncer 新建写入文件的syncer，按RotateConfig选择lumberjack或者按时间切分的writer
+参数:
+*	out     	*output	输出，文件会记录到其中以便关闭
+*	path    	string 	文件路径
+*	rotate    	*RotateConfig	切分配置
+*	compressor	*compressor 	共用的压缩协程，不压缩时为空
+*	onArchived	func(string)	切分出的文件完成后的处理，为空时不处理
+返回值:
+*	zapcore.WriteSyncer	zapcore.WriteSyncer	syncer
+*/
+func (l *Config) newFileSyncer(out *output, path string, rotate *RotateConfig, compressor *compressor, onArchived func(string)) zapcore.WriteSyncer {
+	if rotate.rotating() {
+		writer := newRotateWriter(path, l.Service, rotate, l.location)
+		writer.compressor = compressor
+		writer.onArchived = onArchived
+		writer.busy = out.busy
+		out.closers = append(out.closers, writer)
+		out.files = append(out.files, writer)
+
+		return writer
+	}
+
+	lumberjackLogger := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    rotate.MaxSize, // megabytes
+		MaxBackups: rotate.MaxBackups,
+		MaxAge:     rotate.MaxAge, // days
+	}
+
+	fillLumberjack(lumberjackLogger)
+
+	codec := rotate.compression()
+	out.files = append(out.files, newLumberjackSource(lumberjackLogger, codec))
+
+	// lumberjack只支持gzip，压缩及清理改为由lumberjackWriter完成
+	if compressor != nil || onArchived != nil {
+		writer := newLumberjackWriter(lumberjackLogger, rotate, compressor, onArchived)
+		writer.busy = out.busy
+		out.closers = append(out.closers, writer)
+
+		return zapcore.AddSync(writer)
+	}
+
+	out.closers = append(out.closers, lumberjackLogger)
+
+	return zapcore.AddSync(lumberjackLogger)
+}
+
+// fileBuilder 构建所有日志文件，压缩协程共用，每个切分配置各自上传
+type fileBuilder struct {
+	config     *Config
+	out        *output
+	compressor *compressor
+	uploaders  map[*RotateConfig]*uploader
+	rotates    []*RotateConfig                     // 按创建顺序，启动上传时使用
+	sources    map[*RotateConfig][]retentionSource // 每个切分配置的文件，启动上传时补传
+	logger     *zap.Logger                         // 输出上传结果的日志器
+}
+
+/*
+syncer 新建写入文件的syncer，按需创建压缩协程及上传
+参数:
+*	path  	string       	文件路径
+*	rotate	*RotateConfig	切分配置
+*	dir   	string       	默认的上传清单所在目录
+返回值:
+*	zapcore.WriteSyncer	zapcore.WriteSyncer	syncer
+*	error              	error              	错误
+*/
+func (b *fileBuilder) syncer(path string, rotate *RotateConfig, dir string) (zapcore.WriteSyncer, error) {
+	// 先于文件加入closers，关闭时在文件之后关闭
+	if b.compressor == nil && rotate.compression() != CompressionNone {
+		b.compressor = newCompressor(rotate.CompressWorkers)
+		b.compressor.busy = b.out.busy
+		b.out.closers = append(b.out.closers, b.compressor)
+	}
+
+	archiveUploader, ok := b.uploaders[rotate]
+	if !ok && rotate.Upload != nil {
+		var err error
+		if archiveUploader, err = newUploader(rotate.Upload, dir, b.config.fieldNames); err != nil {
+			return nil, err
+		}
+
+		archiveUploader.busy = b.out.busy
+
+		if b.uploaders == nil {
+			b.uploaders = make(map[*RotateConfig]*uploader)
+			b.sources = make(map[*RotateConfig][]retentionSource)
+		}
+
+		b.uploaders[rotate] = archiveUploader
+		b.rotates = append(b.rotates, rotate)
+		b.out.closers = append(b.out.closers, archiveUploader)
+	}
+
+	files := len(b.out.files)
+	syncer := b.config.newFileSyncer(b.out, path, rotate, b.compressor, archivedHandler(rotate.OnRotate, archiveUploader))
+
+	if archiveUploader != nil {
+		b.sources[rotate] = append(b.sources[rotate], b.out.files[files:]...)
+	}
+
+	return syncer, nil
+}
+
+// start 启动上传，补传各自文件中未上传的文件，handover不为空时只补传其中的文件
+func (b *fileBuilder) start(handover map[string]bool) {
+	for _, rotate := range b.rotates {
+		b.uploaders[rotate].start(b.logger, b.sources[rotate], handover)
+	}
+}
+
+// pendingFiles 所有上传关闭后仍未上传的文件，没有上传时为空
+func (b *fileBuilder) pendingFiles() map[string]bool {
+	if len(b.rotates) == 0 {
+		return nil
+	}
+
+	result := make(map[string]bool)
+
+	for _, rotate := range b.rotates {
+		for _, path := range b.uploaders[rotate].pendingFiles() {
+			result[path] = true
+		}
+	}
+
+	return result
+}
+
+// logDir 日志目录，FilePath为空时使用任意一个LevelToPath或者Routes的目录
+func (l *Config) logDir() string {
+	if l.FilePath != `` {
+		return filepath.Dir(l.FilePath)
+	}
+
+	for _, path := range l.levelToPath {
+		return filepath.Dir(path)
+	}
+
+	if len(l.Routes) > 0 {
+		return filepath.Dir(l.Routes[0].Path)
+	}
+
+	return `.`
+}
+
 func fillLumberjack(lumberjackLogger *lumberjack.Logger) {
 	if lumberjackLogger.MaxSize == 0 {
 		lumberjackLogger.MaxSize = defaultRotateMaxSize
@@ -292,6 +669,15 @@ func fillLumberjack(lumberjackLogger *lumberjack.Logger) {
 	}
 }
 
+// consoleSyncer 控制台输出，终端及管道不支持Sync，写入本身无缓冲，Sync不做处理
+type consoleSyncer struct {
+	io.Writer
+}
+
+func (consoleSyncer) Sync() error {
+	return nil
+}
+
 type levelEnableWithExcept struct {
 	zapcore.LevelEnabler
 	except map[zapcore.Level]bool
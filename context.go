@@ -0,0 +1,91 @@
+package log2
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// LoggerKey context中保存日志器的key
+	LoggerKey = CtxKey(`logger`)
+	// FieldsKey context中保存请求级字段的key
+	FieldsKey = CtxKey(`fields`)
+)
+
+/*
+IntoContext 将日志器放入context，之后可以通过FromContext或Logger.WithContext取出
+参数:
+*	ctx   	context.Context	上下文
+*	logger	Logger         	日志器
+返回值:
+*	context.Context	context.Context	携带日志器的上下文
+*/
+func IntoContext(ctx context.Context, logger Logger) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, LoggerKey, logger)
+}
+
+/*
+FromContext 从context中取出日志器，不存在时使用默认日志器
+参数:
+*	ctx   	context.Context	上下文
+返回值:
+*	Logger	Logger         	日志器，已携带context中的字段
+*/
+func FromContext(ctx context.Context) Logger {
+	if ctx == nil {
+		return Default()
+	}
+
+	if logger, ok := ctx.Value(LoggerKey).(Logger); ok && logger != nil {
+		return logger.WithContext(ctx)
+	}
+
+	return Default().WithContext(ctx)
+}
+
+/*
+ContextWithFields 向context追加请求级字段，Logger.WithContext及*Context系列方法会自动带上
+参数:
+*	ctx   	context.Context	上下文
+*	fields	...zap.Field   	字段
+返回值:
+*	context.Context	context.Context	携带字段的上下文
+*/
+func ContextWithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if len(fields) == 0 {
+		return ctx
+	}
+
+	exists := FieldsFromContext(ctx)
+	merged := make([]zap.Field, 0, len(exists)+len(fields))
+	merged = append(merged, exists...)
+	merged = append(merged, fields...)
+
+	return context.WithValue(ctx, FieldsKey, merged)
+}
+
+/*
+FieldsFromContext 取出context中的请求级字段
+参数:
+*	ctx   	context.Context	上下文
+返回值:
+*	[]zap.Field	[]zap.Field	字段，不存在时为nil
+*/
+func FieldsFromContext(ctx context.Context) []zap.Field {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(FieldsKey).([]zap.Field)
+
+	return fields
+}
@@ -0,0 +1,138 @@
+package log2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestIntoContext 测试日志器放入/取出context
+func TestIntoContext(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	requestLogger := NewLogger(zap.New(core), "request", 0, false, false, nil, nil).With(zap.String(`任务ID`, `abc`))
+
+	ctx := IntoContext(context.Background(), requestLogger)
+
+	FromContext(ctx).Info(`来自context`)
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, `abc`, logs[0].ContextMap()[`任务ID`])
+
+	t.Run("不存在日志器", func(t *testing.T) {
+		require.NotNil(t, FromContext(context.Background()))
+		require.NotNil(t, FromContext(nil)) //nolint:staticcheck // 验证nil context
+		FromContext(context.Background()).Info(`不输出`)
+	})
+}
+
+// TestLogger_WithContext 测试WithContext及*Context系列方法
+func TestLogger_WithContext(t *testing.T) {
+	baseCore, baseRecorded := observer.New(zapcore.DebugLevel)
+	baseLogger := NewLogger(zap.New(baseCore), "base", 0, false, false, nil, nil)
+
+	t.Run("携带字段", func(t *testing.T) {
+		ctx := ContextWithFields(context.Background(), zap.String(`user`, `u1`))
+		ctx = ContextWithFields(ctx, zap.Int(`page`, 2))
+
+		baseLogger.WithContext(ctx).Info(`字段`)
+		baseLogger.DebugContext(ctx, `debug`)
+		baseLogger.InfoContext(ctx, `info`)
+		baseLogger.WarnContext(ctx, `warn`)
+		baseLogger.ErrorContext(ctx, `error`)
+
+		logs := baseRecorded.TakeAll()
+		require.Len(t, logs, 5)
+
+		for _, entry := range logs {
+			require.Equal(t, `u1`, entry.ContextMap()[`user`])
+			require.EqualValues(t, 2, entry.ContextMap()[`page`])
+		}
+
+		require.Equal(t, zapcore.WarnLevel, logs[3].Level)
+	})
+
+	t.Run("合并context中日志器的字段", func(t *testing.T) {
+		requestCore, requestRecorded := observer.New(zapcore.DebugLevel)
+		requestLogger := NewLogger(zap.New(requestCore), "request", 0, false, false, nil, nil).With(zap.String(`任务ID`, `abc`))
+
+		ctx := IntoContext(context.Background(), requestLogger)
+		baseLogger.InfoContext(ctx, `请求`)
+		baseLogger.With(zap.String(`任务ID`, `own`)).InfoContext(ctx, `已有任务ID`)
+
+		require.Equal(t, 0, requestRecorded.Len(), `仍使用当前日志器的输出`)
+
+		logs := baseRecorded.TakeAll()
+		require.Len(t, logs, 2)
+		require.Equal(t, `abc`, logs[0].ContextMap()[`任务ID`])
+		require.Equal(t, []zapcore.Field{zap.String(`任务ID`, `own`)}, logs[1].Context, `已有的字段不重复添加`)
+	})
+
+	t.Run("nil context", func(t *testing.T) {
+		baseLogger.WithContext(nil).Info(`nil`) //nolint:staticcheck // 验证nil context
+		require.Equal(t, 1, baseRecorded.Len())
+		baseRecorded.TakeAll()
+	})
+}
+
+// TestLogger_WithContextCaller 测试context中的日志器与适配器的堆栈跳过对齐
+func TestLogger_WithContextCaller(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	base := NewLogger(zap.New(core, zap.AddCaller()), "", 1, false, false, nil, nil)
+
+	ctx := IntoContext(context.Background(), base.With(zap.String(`任务ID`, `abc`)))
+	gzLogger := NewGoZeroLogger(base).WithContext(ctx)
+
+	gzLogger.Info(`go-zero`)
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, `abc`, logs[0].ContextMap()[`任务ID`])
+	require.Contains(t, logs[0].Caller.File, `context_test.go`)
+}
+
+// TestGormLogger_TraceContext 测试gorm适配器使用context中的日志器
+func TestGormLogger_TraceContext(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	base := NewLogger(zap.New(core), "", 0, false, false, nil, nil)
+	gorm := &gormLogger{Logger: base, slowThreshold: time.Second}
+
+	ctx := ContextWithFields(context.Background(), zap.String(`任务ID`, `abc`))
+	gorm.Trace(ctx, time.Now(), func() (string, int64) { return `SELECT 1`, 1 }, context.Canceled)
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, `abc`, logs[0].ContextMap()[`任务ID`])
+
+	t.Run("保留gorm日志器的名称及级别", func(t *testing.T) {
+		root, err := (&Config{Service: "test", Level: zapcore.DebugLevel, HideConsole: true}).Build(core)
+		require.NoError(t, err)
+
+		root.Derive(`gorm`).SetLevel(zapcore.ErrorLevel)
+		gorm := NewGormLogger(root.Derive(`gorm`), time.Nanosecond, map[string]zapcore.Level{`user`: zapcore.InfoLevel})
+		ctx := context.WithValue(IntoContext(context.Background(), root.StartWithID(`req-1`)), ModuleKey, `user`)
+
+		gorm.Trace(ctx, time.Now().Add(-time.Second), func() (string, int64) { return `SELECT 1`, 1 }, nil)
+		require.Equal(t, 0, recorded.Len(), `gorm的级别生效`)
+
+		gorm.Trace(ctx, time.Now(), func() (string, int64) { return `SELECT 2`, 1 }, context.Canceled)
+
+		logs := recorded.TakeAll()
+		require.Len(t, logs, 1)
+		require.Equal(t, `gorm`, logs[0].LoggerName)
+		require.Equal(t, `req-1`, logs[0].ContextMap()[`任务ID`])
+
+		keys := map[string]int{}
+		for _, field := range logs[0].Context {
+			keys[field.Key]++
+		}
+
+		require.Equal(t, 1, keys[`系统`], `服务名不重复`)
+		require.Equal(t, 1, keys[`任务ID`])
+	})
+}
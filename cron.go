@@ -25,7 +25,7 @@ func NewCronLogger(targetLogger Logger) *cronLogger {
 }
 
 func DeriveCronLogger(baseLogger Logger, topic, method string) Logger {
-	return baseLogger.With(zap.Strings(`topic/method`, []string{topic, method}))
+	return baseLogger.With(zap.Strings(fieldNamesOf(baseLogger)[FieldCronTask], []string{topic, method}))
 }
 
 // cronFormatTimes formats any time.Time values as RFC3339. 这块来自cron库
@@ -0,0 +1,252 @@
+package log2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultDedupeWindow = 1000
+)
+
+// DedupeConfig 去重配置
+type DedupeConfig struct {
+	Window int `yaml:"window"` // 窗口,单位为毫秒,默认1000
+}
+
+func (c *DedupeConfig) tidy() {
+	if c.Window <= 0 {
+		c.Window = defaultDedupeWindow
+	}
+}
+
+// dedupeRecord 窗口内相同的日志，保留第一条的时间
+type dedupeRecord struct {
+	core     zapcore.Core
+	entry    zapcore.Entry
+	fields   []zapcore.Field
+	last     time.Time
+	repeated int
+}
+
+// dedupeKey 去重的key，fields为With及调用时所有字段的哈希，字段不同的日志不合并
+type dedupeKey struct {
+	level   zapcore.Level
+	name    string
+	message string
+	fields  uint64
+}
+
+// hashFields 在seed的基础上计算字段的哈希，与字段的顺序无关
+func hashFields(seed uint64, fields []zapcore.Field) uint64 {
+	if len(fields) == 0 {
+		return seed
+	}
+
+	encoder := zapcore.NewMapObjectEncoder()
+	for i := range fields {
+		fields[i].AddTo(encoder)
+	}
+
+	hash := fnv.New64a()
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], seed)
+	_, _ = hash.Write(buf[:])
+
+	// fmt按key排序输出map
+	_, _ = fmt.Fprint(hash, encoder.Fields)
+
+	return hash.Sum64()
+}
+
+// deduper 同一组去重core共享的窗口状态，有日志时由一个ticker按窗口统一输出
+type deduper struct {
+	lock    sync.Mutex
+	window  time.Duration
+	records map[dedupeKey]*dedupeRecord
+	running bool // ticker协程是否在运行，没有日志时退出
+
+	repeatedKey, firstKey, lastKey string
+}
+
+func newDeduper(window time.Duration, names map[string]string) *deduper {
+	return &deduper{
+		window:      window,
+		records:     make(map[dedupeKey]*dedupeRecord),
+		repeatedKey: names[FieldRepeated],
+		firstKey:    names[FieldFirst],
+		lastKey:     names[FieldLast],
+	}
+}
+
+// add 记录一条日志，窗口内第一次出现时复制字段，withHash为core的With字段的哈希
+func (d *deduper) add(core zapcore.Core, withHash uint64, entry zapcore.Entry, fields []zapcore.Field) {
+	key := dedupeKey{level: entry.Level, name: entry.LoggerName, message: entry.Message, fields: hashFields(withHash, fields)}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if record, exist := d.records[key]; exist {
+		record.repeated++
+		record.last = entry.Time
+
+		return
+	}
+
+	d.records[key] = &dedupeRecord{
+		core:     core,
+		entry:    entry,
+		fields:   append([]zapcore.Field(nil), fields...),
+		last:     entry.Time,
+		repeated: 1,
+	}
+
+	if !d.running {
+		d.running = true
+
+		go d.run()
+	}
+}
+
+func (d *deduper) run() {
+	ticker := time.NewTicker(d.window)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !d.flush(true) {
+			return
+		}
+	}
+}
+
+/*
+flush 输出当前窗口的所有日志
+参数:
+*	ticker	bool	是否由ticker协程调用，没有日志时ticker协程退出
+返回值:
+*	bool  	bool	是否有日志
+*/
+func (d *deduper) flush(ticker bool) bool {
+	d.lock.Lock()
+	records := d.records
+
+	if len(records) == 0 {
+		if ticker {
+			d.running = false
+		}
+
+		d.lock.Unlock()
+
+		return false
+	}
+
+	d.records = make(map[dedupeKey]*dedupeRecord, len(records))
+	d.lock.Unlock()
+
+	for _, record := range records {
+		d.write(record)
+	}
+
+	return true
+}
+
+// write 输出合并后的一条日志，重复时携带次数及首末时间
+func (d *deduper) write(record *dedupeRecord) {
+	fields := record.fields
+
+	if record.repeated > 1 {
+		fields = append(fields[:len(fields):len(fields)],
+			zap.Int(d.repeatedKey, record.repeated), zap.Time(d.firstKey, record.entry.Time), zap.Time(d.lastKey, record.last))
+	}
+
+	// 底层可能是按级别区分输出的tee，需要重新Check
+	if checked := record.core.Check(record.entry, nil); checked != nil {
+		checked.Write(fields...)
+	}
+}
+
+// dedupeCore 将窗口内级别、日志器名称、消息及字段都相同的日志合并为一条
+// 日志在窗口结束时输出，重复时携带repeated次数及first/last时间
+type dedupeCore struct {
+	zapcore.Core
+	deduper  *deduper
+	withHash uint64 // With添加的字段的哈希
+}
+
+/*
+NewDedupeCore 新建去重core，可作为Config.Build的额外core的包装，字段名使用zh方案
+参数:
+*	core  	zapcore.Core 	底层core
+*	window	time.Duration	窗口
+返回值:
+*	zapcore.Core	zapcore.Core	去重core，Sync时输出所有未结束窗口的合并日志
+*/
+func NewDedupeCore(core zapcore.Core, window time.Duration) zapcore.Core {
+	return newDedupeCore(core, window, zhFieldNames)
+}
+
+func newDedupeCore(core zapcore.Core, window time.Duration, names map[string]string) zapcore.Core {
+	return &dedupeCore{Core: core, deduper: newDeduper(window, names)}
+}
+
+/*
+WithDedupe 返回对日志去重的日志器，适合pulsar、go-micro等重复日志较多的适配器
+参数:
+*	target	Logger       	日志器
+*	window	time.Duration	窗口
+返回值:
+*	Logger	Logger       	日志器，不是本包的日志器时原样返回
+*/
+func WithDedupe(target Logger, window time.Duration) Logger {
+	l, ok := target.(*logger)
+	if !ok || l.underlying == nil {
+		return target
+	}
+
+	names := fieldNamesOf(target)
+	underlying := l.underlying.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return newDedupeCore(core, window, names)
+	}))
+
+	return l.inherit(NewLogger(underlying, l.name, -1, false, false, l.levelToPath, l.duplicateKeys.Copy(), l.fields...))
+}
+
+func (c *dedupeCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupeCore{Core: c.Core.With(fields), deduper: c.deduper, withHash: hashFields(c.withHash, fields)}
+}
+
+func (c *dedupeCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c *dedupeCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	// 高于Error的日志之后进程可能退出，不做合并
+	if entry.Level <= zapcore.ErrorLevel {
+		c.deduper.add(c.Core, c.withHash, entry, fields)
+
+		return nil
+	}
+
+	if checked := c.Core.Check(entry, nil); checked != nil {
+		checked.Write(fields...)
+	}
+
+	return nil
+}
+
+func (c *dedupeCore) Sync() error {
+	c.deduper.flush(false)
+
+	return c.Core.Sync()
+}
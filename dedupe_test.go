@@ -0,0 +1,119 @@
+package log2
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestDedupeCore 测试窗口内级别、日志器名称、消息及字段相同的日志合并为一条
+func TestDedupeCore(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	logger := zap.New(NewDedupeCore(core, 50*time.Millisecond))
+
+	for i := 0; i < 5; i++ {
+		logger.With(zap.String(`ctx`, `x`)).Info(`重复`, zap.String(`topic`, `a`))
+	}
+
+	logger.With(zap.String(`ctx`, `y`)).Info(`重复`, zap.String(`topic`, `a`))
+	logger.With(zap.String(`ctx`, `x`)).Info(`重复`, zap.String(`topic`, `b`))
+	logger.Warn(`重复`, zap.String(`topic`, `a`))
+
+	// 窗口结束时输出
+	require.Equal(t, 0, recorded.Len())
+
+	require.Eventually(t, func() bool {
+		return recorded.Len() == 4
+	}, time.Second, 5*time.Millisecond)
+
+	summary := recorded.FilterFieldKey(`repeated`).All()
+	require.Len(t, summary, 1, `只输出合并后的一条`)
+	require.EqualValues(t, 5, summary[0].ContextMap()[`repeated`])
+	require.Equal(t, `a`, summary[0].ContextMap()[`topic`])
+	require.Equal(t, `x`, summary[0].ContextMap()[`ctx`])
+	require.Contains(t, summary[0].ContextMap(), `first`)
+	require.Contains(t, summary[0].ContextMap(), `last`)
+
+	require.Len(t, recorded.FilterField(zap.String(`ctx`, `y`)).All(), 1, `With的字段不同时不合并`)
+	require.Len(t, recorded.FilterField(zap.String(`topic`, `b`)).All(), 1, `调用的字段不同时不合并`)
+	require.NotContains(t, recorded.FilterLevelExact(zapcore.WarnLevel).All()[0].ContextMap(), `repeated`, `没有重复时原样输出`)
+
+	t.Run("Sync输出未结束的窗口", func(t *testing.T) {
+		recorded.TakeAll()
+
+		logger := zap.New(NewDedupeCore(core, time.Hour))
+		logger.Error(`错误`, zap.Error(errors.New(`x`)))
+		logger.Error(`错误`, zap.Error(errors.New(`x`)))
+		require.Equal(t, 0, recorded.Len())
+
+		require.NoError(t, logger.Sync())
+		require.Equal(t, 1, recorded.Len())
+		require.EqualValues(t, 2, recorded.All()[0].ContextMap()[`repeated`])
+	})
+}
+
+// TestConfig_Dedupe 测试通过配置开启去重，且保留按级别的输出
+func TestConfig_Dedupe(t *testing.T) {
+	hook := &bufferHook{minLevel: zapcore.ErrorLevel}
+	cfg := &Config{Service: "test", HideConsole: true, Hooks: []Hook{hook}, Dedupe: &DedupeConfig{Window: 60000}}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		root.Info(`info`)
+	}
+
+	require.Equal(t, 0, recorded.Len())
+
+	require.NoError(t, root.Sync())
+	require.Equal(t, 1, recorded.Len())
+	require.EqualValues(t, 3, recorded.All()[0].ContextMap()[`repeated`])
+	require.Empty(t, hook.String())
+}
+
+// TestConfig_DedupeFieldNames 测试合并的字段名使用配置的字段名
+func TestConfig_DedupeFieldNames(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	cfg := &Config{
+		Service:     "test",
+		HideConsole: true,
+		FieldNames:  map[string]string{FieldRepeated: `重复次数`},
+		Dedupe:      &DedupeConfig{Window: 60000},
+	}
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	root.Info(`info`)
+	root.Info(`info`)
+	require.NoError(t, root.Sync())
+
+	require.Equal(t, 1, recorded.Len())
+	require.EqualValues(t, 2, recorded.All()[0].ContextMap()[`重复次数`])
+}
+
+// TestWithDedupe 测试适配器使用去重日志器
+func TestWithDedupe(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	base := NewLogger(zap.New(core), "pulsar", 0, false, false, nil, nil)
+
+	pulsar := NewPulsarLogger(WithDedupe(base, time.Hour))
+	for i := 0; i < 10; i++ {
+		pulsar.Info(`重连`)
+	}
+
+	require.Equal(t, 0, recorded.Len())
+
+	require.NoError(t, pulsar.Sync())
+	require.Equal(t, 1, recorded.Len())
+	require.EqualValues(t, 10, recorded.All()[0].ContextMap()[`repeated`])
+
+	require.Equal(t, Logger(&logger{}), WithDedupe(&logger{}, time.Second))
+}
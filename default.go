@@ -0,0 +1,43 @@
+package log2
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// nopLogger 不输出任何内容的日志器
+	nopLogger = NewLogger(zap.NewNop(), ``, -1, false, false, nil, nil)
+
+	defaultLock   sync.RWMutex
+	defaultLogger Logger = nopLogger
+)
+
+/*
+SetDefault 设置默认日志器，FromContext在context中没有日志器时使用
+参数:
+*	logger	Logger	日志器，为nil时恢复为不输出的日志器
+*/
+func SetDefault(logger Logger) {
+	if logger == nil {
+		logger = nopLogger
+	}
+
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+
+	defaultLogger = logger
+}
+
+/*
+Default 获取默认日志器
+返回值:
+*	Logger	Logger	默认日志器，未设置时不输出任何内容
+*/
+func Default() Logger {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+
+	return defaultLogger
+}
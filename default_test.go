@@ -0,0 +1,60 @@
+package log2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestDefault 测试默认日志器
+func TestDefault(t *testing.T) {
+	defer SetDefault(nil)
+
+	require.Equal(t, nopLogger, Default())
+	Default().Info(`不输出`)
+
+	core, recorded := observer.New(zapcore.DebugLevel)
+	SetDefault(NewLogger(zap.New(core), "default", 0, false, false, nil, nil))
+
+	FromContext(context.Background()).Info(`默认`)
+	require.Equal(t, 1, recorded.Len())
+
+	SetDefault(nil)
+	require.Equal(t, nopLogger, Default())
+}
+
+// TestConfig_BuildIndependent 测试同一进程中构建的多个日志器互不影响
+func TestConfig_BuildIndependent(t *testing.T) {
+	hookA := &bufferHook{minLevel: zapcore.DebugLevel}
+	hookB := &bufferHook{minLevel: zapcore.DebugLevel}
+
+	loggerA, err := (&Config{Service: "a", HideConsole: true, JSON: true, Hooks: []Hook{hookA}}).Build()
+	require.NoError(t, err)
+
+	loggerB, err := (&Config{Service: "b", HideConsole: true, Hooks: []Hook{hookB}}).Build()
+	require.NoError(t, err)
+
+	loggerA.Info(`from-a`)
+	loggerB.Info(`from-b`)
+
+	require.Contains(t, hookA.String(), `from-a`)
+	require.NotContains(t, hookA.String(), `from-b`)
+	require.Contains(t, hookB.String(), `from-b`)
+	require.NotContains(t, hookB.String(), `from-a`)
+
+	// A 使用JSON编码，B 使用console编码
+	require.Contains(t, hookA.String(), `"M":"from-a"`)
+	require.NotContains(t, hookB.String(), `"M":`)
+
+	loggerA.SetLevel(zapcore.ErrorLevel)
+	loggerA.Info(`a-hidden`)
+	loggerB.Info(`b-visible`)
+
+	require.NotContains(t, hookA.String(), `a-hidden`)
+	require.Contains(t, hookB.String(), `b-visible`)
+	require.Equal(t, zapcore.InfoLevel, loggerB.Level())
+}
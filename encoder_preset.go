@@ -0,0 +1,176 @@
+package log2
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// 编码器预设，决定键名、级别格式、时间格式及严重程度的映射
+const (
+	// EncoderClassic 默认，单字母键名，console格式为彩色级别
+	EncoderClassic = `classic`
+	// EncoderECS Elastic Common Schema
+	EncoderECS = `ecs`
+	// EncoderGCP Google Cloud Logging结构化日志
+	EncoderGCP = `gcp-cloud-logging`
+	// EncoderDatadog Datadog标准属性，时间为毫秒时间戳
+	EncoderDatadog = `datadog`
+	// EncoderLoki Grafana Loki常用的键名
+	EncoderLoki = `loki`
+)
+
+const (
+	// ecsVersion 输出的ecs.version
+	ecsVersion = `8.11.0`
+)
+
+func checkEncoderPreset(preset string) error {
+	switch preset {
+	case ``, EncoderClassic, EncoderECS, EncoderGCP, EncoderDatadog, EncoderLoki:
+		return nil
+	default:
+		return errors.Errorf(`未知的编码器预设[%s]`, preset)
+	}
+}
+
+/*
+applyEncoderPreset 按预设修改编码器配置，classic不做修改
+参数:
+*	config	*zapcore.EncoderConfig	编码器配置
+*	preset	string                	预设
+*	full  	bool                  	调用位置是否输出完整路径
+*/
+func (l *Config) applyEncoderPreset(config *zapcore.EncoderConfig, preset string, full bool) {
+	rfc3339Nano := func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(t.In(l.location).Format(time.RFC3339Nano))
+	}
+
+	switch preset {
+	case EncoderECS:
+		config.TimeKey = `@timestamp`
+		config.LevelKey = `log.level`
+		config.NameKey = `log.logger`
+		config.CallerKey = zapcore.OmitKey // 文件名及行号由ecsEncoder分别输出
+		config.FunctionKey = `log.origin.function`
+		config.MessageKey = `message`
+		config.StacktraceKey = `error.stack_trace`
+		config.EncodeLevel = zapcore.LowercaseLevelEncoder
+		config.EncodeTime = rfc3339Nano
+		config.EncodeDuration = zapcore.NanosDurationEncoder
+	case EncoderGCP:
+		config.TimeKey = `time`
+		config.LevelKey = `severity`
+		config.NameKey = `logger`
+		config.CallerKey = `logging.googleapis.com/sourceLocation`
+		config.FunctionKey = zapcore.OmitKey
+		config.MessageKey = `message`
+		config.StacktraceKey = `stack_trace`
+		config.EncodeLevel = gcpSeverityEncoder
+		config.EncodeTime = rfc3339Nano
+		config.EncodeCaller = gcpSourceLocationEncoder(full, l.format() == FormatJSON)
+	case EncoderDatadog:
+		config.TimeKey = `timestamp`
+		config.LevelKey = `status`
+		config.NameKey = `logger.name`
+		config.CallerKey = `logger.caller`
+		config.FunctionKey = zapcore.OmitKey
+		config.MessageKey = `message`
+		config.StacktraceKey = `error.stack`
+		config.EncodeLevel = zapcore.LowercaseLevelEncoder
+		config.EncodeTime = zapcore.EpochMillisTimeEncoder
+		config.EncodeDuration = zapcore.NanosDurationEncoder
+	case EncoderLoki:
+		config.TimeKey = `ts`
+		config.LevelKey = `level`
+		config.NameKey = `logger`
+		config.CallerKey = `caller`
+		config.FunctionKey = zapcore.OmitKey
+		config.MessageKey = `msg`
+		config.StacktraceKey = `stacktrace`
+		config.EncodeLevel = zapcore.LowercaseLevelEncoder
+		config.EncodeTime = rfc3339Nano
+	}
+}
+
+// wrapEncoderPreset 添加预设要求的固定字段，ECS另外包装以分别输出调用位置的文件名及行号
+func wrapEncoderPreset(encoder zapcore.Encoder, preset string) zapcore.Encoder {
+	if preset != EncoderECS {
+		return encoder
+	}
+
+	encoder.AddString(`ecs.version`, ecsVersion)
+
+	return ecsEncoder{Encoder: encoder}
+}
+
+// ecsEncoder 将调用位置输出为log.origin.file.name(文件名)及log.origin.file.line(行号)两个字段
+type ecsEncoder struct {
+	zapcore.Encoder
+}
+
+func (e ecsEncoder) Clone() zapcore.Encoder {
+	return ecsEncoder{Encoder: e.Encoder.Clone()}
+}
+
+func (e ecsEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	if entry.Caller.Defined {
+		fields = append([]zapcore.Field{
+			zap.String(`log.origin.file.name`, filepath.Base(entry.Caller.File)),
+			zap.Int(`log.origin.file.line`, entry.Caller.Line),
+		}, fields...)
+	}
+
+	return e.Encoder.EncodeEntry(entry, fields)
+}
+
+// gcpSeverityEncoder 按Cloud Logging的LogSeverity输出级别
+func gcpSeverityEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch level {
+	case zapcore.DebugLevel:
+		enc.AppendString(`DEBUG`)
+	case zapcore.InfoLevel:
+		enc.AppendString(`INFO`)
+	case zapcore.WarnLevel:
+		enc.AppendString(`WARNING`)
+	case zapcore.ErrorLevel:
+		enc.AppendString(`ERROR`)
+	case zapcore.DPanicLevel:
+		enc.AppendString(`CRITICAL`)
+	case zapcore.PanicLevel:
+		enc.AppendString(`ALERT`)
+	case zapcore.FatalLevel:
+		enc.AppendString(`EMERGENCY`)
+	default:
+		enc.AppendString(`DEFAULT`)
+	}
+}
+
+// gcpSourceLocationEncoder json格式输出Cloud Logging的sourceLocation对象，其他格式输出file:line文本
+func gcpSourceLocationEncoder(full, object bool) zapcore.CallerEncoder {
+	return func(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+		file := caller.TrimmedPath()
+		if full {
+			file = caller.FullPath()
+		}
+
+		arrayEncoder, ok := enc.(zapcore.ArrayEncoder)
+		if !object || !ok {
+			enc.AppendString(file)
+
+			return
+		}
+
+		_ = arrayEncoder.AppendObject(zapcore.ObjectMarshalerFunc(func(objectEncoder zapcore.ObjectEncoder) error {
+			objectEncoder.AddString(`file`, caller.File)
+			objectEncoder.AddInt(`line`, caller.Line)
+			objectEncoder.AddString(`function`, caller.Function)
+
+			return nil
+		}))
+	}
+}
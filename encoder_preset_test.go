@@ -0,0 +1,91 @@
+package log2
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// TestConfig_EncoderPreset 测试各编码器预设的键名、级别及时间格式
+func TestConfig_EncoderPreset(t *testing.T) {
+	tests := []struct {
+		preset string
+		check  func(t *testing.T, record map[string]interface{})
+	}{
+		{EncoderClassic, func(t *testing.T, record map[string]interface{}) {
+			require.Equal(t, `警告`, record[`M`])
+			require.Equal(t, `WARN`, record[`L`], `json不带颜色`)
+			require.Equal(t, `sub`, record[`N`])
+			require.Equal(t, `test`, record[`系统`])
+		}},
+		{EncoderECS, func(t *testing.T, record map[string]interface{}) {
+			require.Equal(t, `警告`, record[`message`])
+			require.Equal(t, `warn`, record[`log.level`])
+			require.Equal(t, `sub`, record[`log.logger`])
+			require.Equal(t, ecsVersion, record[`ecs.version`])
+			require.Equal(t, `test`, record[`service.name`], `默认使用ecs字段名`)
+			require.Equal(t, `encoder_preset_test.go`, record[`log.origin.file.name`], `只输出文件名`)
+			require.Greater(t, record[`log.origin.file.line`], float64(0))
+
+			_, err := time.Parse(time.RFC3339Nano, record[`@timestamp`].(string))
+			require.NoError(t, err)
+		}},
+		{EncoderGCP, func(t *testing.T, record map[string]interface{}) {
+			require.Equal(t, `警告`, record[`message`])
+			require.Equal(t, `WARNING`, record[`severity`])
+			require.Contains(t, record[`logging.googleapis.com/sourceLocation`].(map[string]interface{})[`file`], `encoder_preset_test.go`)
+
+			_, err := time.Parse(time.RFC3339Nano, record[`time`].(string))
+			require.NoError(t, err)
+		}},
+		{EncoderDatadog, func(t *testing.T, record map[string]interface{}) {
+			require.Equal(t, `警告`, record[`message`])
+			require.Equal(t, `warn`, record[`status`])
+			require.Equal(t, `sub`, record[`logger.name`])
+			require.InDelta(t, float64(time.Now().UnixMilli()), record[`timestamp`], 60000)
+		}},
+		{EncoderLoki, func(t *testing.T, record map[string]interface{}) {
+			require.Equal(t, `警告`, record[`msg`])
+			require.Equal(t, `warn`, record[`level`])
+			require.Equal(t, `sub`, record[`logger`])
+
+			_, err := time.Parse(time.RFC3339Nano, record[`ts`].(string))
+			require.NoError(t, err)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.preset, func(t *testing.T) {
+			hook := &bufferHook{minLevel: zapcore.DebugLevel}
+			cfg := &Config{Service: "test", JSON: true, HideConsole: true, EncoderPreset: test.preset, Hooks: []Hook{hook}}
+
+			root, err := cfg.Build()
+			require.NoError(t, err)
+
+			root.Derive(`sub`).Warn(`警告`)
+
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(hook.String()), &record))
+			test.check(t, record)
+		})
+	}
+
+	t.Run("console输出", func(t *testing.T) {
+		hook := &bufferHook{minLevel: zapcore.DebugLevel}
+		root, err := (&Config{Service: "test", HideConsole: true, EncoderPreset: EncoderGCP, Hooks: []Hook{hook}}).Build()
+		require.NoError(t, err)
+
+		root.Error(`错误`)
+		require.Contains(t, hook.String(), `ERROR`)
+		require.Regexp(t, `\t\w+/encoder_preset_test.go:\d+\t`, hook.String(), `输出file:line而不是对象`)
+		require.NotContains(t, hook.String(), `map[`)
+	})
+
+	t.Run("未知预设", func(t *testing.T) {
+		_, err := (&Config{Service: "test", EncoderPreset: `x`}).Build()
+		require.Error(t, err)
+	})
+}
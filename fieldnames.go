@@ -0,0 +1,245 @@
+package log2
+
+import (
+	"github.com/pkg/errors"
+)
+
+// 内置字段的标识，作为Config.FieldNames的键
+const (
+	FieldService   = `service`   // 服务名称
+	FieldTaskID    = `taskID`    // 任务ID
+	FieldElapsed   = `elapsed`   // 耗时
+	FieldRows      = `rows`      // 影响行数
+	FieldThreshold = `threshold` // 慢查询阈值
+	FieldError     = `error`     // 错误
+	FieldRequestID = `requestID` // 请求ID
+	FieldArgs      = `args`      // 参数
+	FieldValue     = `value`     // 值
+	FieldSQL       = `sql`       // SQL语句
+	FieldCommand   = `command`   // mongo命令
+	FieldResult    = `result`    // mongo命令结果
+	FieldReason    = `reason`    // 失败原因
+	FieldMethod    = `method`    // HTTP方法
+	FieldPath      = `path`      // HTTP路径
+	FieldQuery     = `query`     // HTTP查询参数
+	FieldStatus    = `status`    // HTTP状态码
+	FieldBytes     = `bytes`     // 响应字节数
+	FieldClientIP  = `clientIP`  // 客户端IP
+	FieldBody      = `body`      // 请求体
+	FieldStack     = `stack`     // 堆栈
+	FieldRPCMethod = `rpcMethod` // gRPC完整方法名
+	FieldRPCCode   = `rpcCode`   // gRPC状态码
+	FieldPeer      = `peer`      // 对端地址
+	FieldFile      = `file`      // 日志文件路径
+	FieldMessage   = `message`   // 限流汇总中被抑制日志的消息
+	FieldCount     = `count`     // 限流汇总中被抑制的条数
+	FieldRepeated  = `repeated`  // 去重合并的条数
+	FieldFirst     = `first`     // 去重合并的第一条的时间
+	FieldLast      = `last`      // 去重合并的最后一条的时间
+	FieldTraceID   = `traceID`   // 链路ID
+	FieldSpanID    = `spanID`    // span ID
+	FieldTraceFlag = `traceFlag` // 链路标志
+	FieldConfig    = `config`    // 配置文件路径
+	FieldCronTask  = `cronTask`  // 定时任务的topic及method
+)
+
+// 内置的字段名方案
+const (
+	FieldPresetZh   = `zh`
+	FieldPresetEn   = `en`
+	FieldPresetECS  = `ecs`
+	FieldPresetOTel = `otel`
+)
+
+var (
+	zhFieldNames = map[string]string{
+		FieldService:   `系统`,
+		FieldTaskID:    `任务ID`,
+		FieldElapsed:   `耗时`,
+		FieldRows:      `影响行数`,
+		FieldThreshold: `阈值`,
+		FieldError:     `错误`,
+		FieldRequestID: `请求ID`,
+		FieldArgs:      `参数`,
+		FieldValue:     `值`,
+		FieldSQL:       `SQL`,
+		FieldCommand:   `command`,
+		FieldResult:    `result`,
+		FieldReason:    `原因`,
+		FieldMethod:    `方法`,
+		FieldPath:      `路径`,
+		FieldQuery:     `参数`,
+		FieldStatus:    `状态码`,
+		FieldBytes:     `字节数`,
+		FieldClientIP:  `客户端IP`,
+		FieldBody:      `请求体`,
+		FieldStack:     `堆栈`,
+		FieldRPCMethod: `方法`,
+		FieldRPCCode:   `状态码`,
+		FieldPeer:      `对端`,
+		FieldFile:      `文件`,
+		FieldMessage:   `消息`,
+		FieldCount:     `次数`,
+		FieldRepeated:  `repeated`,
+		FieldFirst:     `first`,
+		FieldLast:      `last`,
+		FieldTraceID:   `trace_id`,
+		FieldSpanID:    `span_id`,
+		FieldTraceFlag: `trace_flags`,
+		FieldConfig:    `路径`,
+		FieldCronTask:  `topic/method`,
+	}
+
+	enFieldNames = map[string]string{
+		FieldService:   `service`,
+		FieldTaskID:    `task_id`,
+		FieldElapsed:   `elapsed`,
+		FieldRows:      `rows_affected`,
+		FieldThreshold: `threshold`,
+		FieldError:     `error`,
+		FieldRequestID: `request_id`,
+		FieldArgs:      `args`,
+		FieldValue:     `value`,
+		FieldSQL:       `sql`,
+		FieldCommand:   `command`,
+		FieldResult:    `result`,
+		FieldReason:    `reason`,
+		FieldMethod:    `method`,
+		FieldPath:      `path`,
+		FieldQuery:     `query`,
+		FieldStatus:    `status`,
+		FieldBytes:     `bytes`,
+		FieldClientIP:  `client_ip`,
+		FieldBody:      `body`,
+		FieldStack:     `stack`,
+		FieldRPCMethod: `rpc_method`,
+		FieldRPCCode:   `rpc_code`,
+		FieldPeer:      `peer`,
+		FieldFile:      `file`,
+		FieldMessage:   `suppressed_message`,
+		FieldCount:     `count`,
+		FieldRepeated:  `repeated`,
+		FieldFirst:     `first`,
+		FieldLast:      `last`,
+		FieldTraceID:   `trace_id`,
+		FieldSpanID:    `span_id`,
+		FieldTraceFlag: `trace_flags`,
+		FieldConfig:    `config_path`,
+		FieldCronTask:  `topic/method`,
+	}
+
+	fieldPresets = map[string]map[string]string{
+		FieldPresetZh: zhFieldNames,
+		FieldPresetEn: enFieldNames,
+		FieldPresetECS: mergeFieldNames(enFieldNames, map[string]string{
+			FieldService:   `service.name`,
+			FieldTaskID:    `transaction.id`,
+			FieldElapsed:   `event.duration`,
+			FieldError:     `error.message`,
+			FieldRequestID: `db.request_id`,
+			FieldSQL:       `db.statement`,
+			FieldCommand:   `db.statement`,
+			FieldReason:    `error.message`,
+			FieldMethod:    `http.request.method`,
+			FieldPath:      `url.path`,
+			FieldQuery:     `url.query`,
+			FieldStatus:    `http.response.status_code`,
+			FieldBytes:     `http.response.body.bytes`,
+			FieldClientIP:  `client.ip`,
+			FieldBody:      `http.request.body.content`,
+			FieldStack:     `error.stack_trace`,
+			FieldRPCMethod: `rpc.method`,
+			FieldRPCCode:   `rpc.grpc.status_code`,
+			FieldPeer:      `destination.address`,
+			FieldFile:      `file.path`,
+			FieldMessage:   `event.original`,
+			FieldFirst:     `event.start`,
+			FieldLast:      `event.end`,
+			FieldTraceID:   `trace.id`,
+			FieldSpanID:    `span.id`,
+			FieldTraceFlag: `trace.flags`,
+			FieldConfig:    `file.path`,
+		}),
+		FieldPresetOTel: mergeFieldNames(enFieldNames, map[string]string{
+			FieldService:   `service.name`,
+			FieldTaskID:    `task.id`,
+			FieldElapsed:   `duration`,
+			FieldRows:      `db.response.returned_rows`,
+			FieldError:     `exception.message`,
+			FieldRequestID: `db.request.id`,
+			FieldSQL:       `db.query.text`,
+			FieldCommand:   `db.query.text`,
+			FieldReason:    `exception.message`,
+			FieldMethod:    `http.request.method`,
+			FieldPath:      `url.path`,
+			FieldQuery:     `url.query`,
+			FieldStatus:    `http.response.status_code`,
+			FieldBytes:     `http.response.body.size`,
+			FieldClientIP:  `client.address`,
+			FieldBody:      `http.request.body`,
+			FieldStack:     `exception.stacktrace`,
+			FieldRPCMethod: `rpc.method`,
+			FieldRPCCode:   `rpc.grpc.status_code`,
+			FieldPeer:      `network.peer.address`,
+			FieldFile:      `log.file.path`,
+			FieldConfig:    `file.path`,
+		}),
+	}
+)
+
+func mergeFieldNames(base, overrides map[string]string) map[string]string {
+	result := make(map[string]string, len(base))
+
+	for field, name := range base {
+		result[field] = name
+	}
+
+	for field, name := range overrides {
+		result[field] = name
+	}
+
+	return result
+}
+
+/*
+resolveFieldNames 按方案及覆盖项得到最终的字段名
+参数:
+*	preset   	string           	方案，为空时为zh
+*	overrides	map[string]string	覆盖的字段名，键为Field开头的常量
+返回值:
+*	map[string]string	map[string]string	字段名
+*	error            	error            	错误
+*/
+func resolveFieldNames(preset string, overrides map[string]string) (map[string]string, error) {
+	if preset == `` {
+		preset = FieldPresetZh
+	}
+
+	base, exist := fieldPresets[preset]
+	if !exist {
+		return nil, errors.Errorf(`未知的字段名方案[%s]`, preset)
+	}
+
+	for field, name := range overrides {
+		if _, exist = base[field]; !exist {
+			return nil, errors.Errorf(`未知的字段[%s]`, field)
+		}
+
+		if name == `` {
+			return nil, errors.Errorf(`字段[%s]的名称为空`, field)
+		}
+	}
+
+	return mergeFieldNames(base, overrides), nil
+}
+
+// fieldNamesOf 获取日志器使用的字段名，不是通过Config.Build构建的日志器使用zh方案
+func fieldNamesOf(target Logger) map[string]string {
+	if result := instanceOf(target); result != nil {
+		if out := result.output.Load(); out != nil && out.fieldNames != nil {
+			return out.fieldNames
+		}
+	}
+
+	return zhFieldNames
+}
@@ -0,0 +1,98 @@
+package log2
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	microlog "go-micro.dev/v5/logger"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestResolveFieldNames 测试字段名方案及覆盖
+func TestResolveFieldNames(t *testing.T) {
+	for preset := range fieldPresets {
+		names, err := resolveFieldNames(preset, nil)
+		require.NoError(t, err, preset)
+		require.Len(t, names, len(zhFieldNames), preset)
+	}
+
+	names, err := resolveFieldNames(``, map[string]string{FieldTaskID: `tid`})
+	require.NoError(t, err)
+	require.Equal(t, `tid`, names[FieldTaskID])
+	require.Equal(t, `系统`, names[FieldService])
+	require.Equal(t, `任务ID`, zhFieldNames[FieldTaskID], `不影响方案本身`)
+
+	_, err = resolveFieldNames(`x`, nil)
+	require.Error(t, err)
+
+	_, err = resolveFieldNames(FieldPresetEn, map[string]string{`x`: `y`})
+	require.Error(t, err)
+
+	_, err = resolveFieldNames(FieldPresetEn, map[string]string{FieldSQL: ``})
+	require.Error(t, err)
+}
+
+// TestConfig_FieldNames 测试内置字段及适配器使用配置的字段名
+func TestConfig_FieldNames(t *testing.T) {
+	cfg := &Config{
+		Service:     "test",
+		Level:       zapcore.DebugLevel,
+		HideConsole: true,
+		FieldPreset: FieldPresetECS,
+		FieldNames:  map[string]string{FieldRows: `db.rows`, FieldCronTask: `cron.job`},
+	}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	root.Start().Info(`开始`)
+
+	gorm := NewGormLogger(root, time.Nanosecond, nil)
+	gorm.Trace(context.Background(), time.Now().Add(-time.Second), func() (string, int64) {
+		return `SELECT 1`, 1
+	}, nil)
+	gorm.Trace(context.Background(), time.Now(), func() (string, int64) {
+		return `SELECT 2`, 0
+	}, errors.New(`x`))
+
+	NewPulsarLogger(root).Info(`a`)
+	NewMicroLogger(root).Log(microlog.InfoLevel, `b`)
+	DeriveCronLogger(root, `topic`, `method`).Info(`c`)
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 6)
+
+	require.Equal(t, `test`, logs[0].ContextMap()[`service.name`])
+	require.Regexp(t, `^[0-9a-f]{24}$`, logs[0].ContextMap()[`transaction.id`])
+	require.NotContains(t, logs[0].ContextMap(), `系统`)
+
+	require.Equal(t, `SELECT 1`, logs[1].ContextMap()[`db.statement`])
+	require.EqualValues(t, 1, logs[1].ContextMap()[`db.rows`])
+	require.Contains(t, logs[1].ContextMap(), `event.duration`)
+	require.Equal(t, `x`, logs[2].ContextMap()[`error.message`])
+
+	require.Contains(t, logs[3].ContextMap(), `args`)
+	require.Contains(t, logs[4].ContextMap(), `args`)
+	require.Equal(t, []interface{}{`topic`, `method`}, logs[5].ContextMap()[`cron.job`])
+
+	t.Run("默认为zh", func(t *testing.T) {
+		root, err := (&Config{Service: "test", HideConsole: true, TaskID: &TaskIDConfig{Generator: IDUUID}}).Build(core)
+		require.NoError(t, err)
+
+		root.Start().Info(`开始`)
+		NewPulsarLogger(root).Info(`a`)
+		NewMicroLogger(root).Log(microlog.InfoLevel, `b`)
+
+		logs := recorded.TakeAll()
+		require.Equal(t, `test`, logs[0].ContextMap()[`系统`])
+		require.Contains(t, logs[0].ContextMap(), `任务ID`)
+		require.Contains(t, logs[1].ContextMap(), `参数`)
+		require.Contains(t, logs[2].ContextMap(), ``, `micro沿用空字段名`)
+		require.NotContains(t, logs[2].ContextMap(), `参数`)
+	})
+}
@@ -136,11 +136,12 @@ func (g *GoZeroLogger) WithCallerSkip(skip int) *GoZeroLogger {
 	}
 }
 
-// WithContext 返回一个带上下文的日志器（当前实现忽略context）
+// WithContext 返回一个带上下文的日志器，使用context中的日志器及字段
 func (g *GoZeroLogger) WithContext(ctx context.Context) *GoZeroLogger {
-	// 当前实现不处理context，直接返回自身
-	// 如果需要支持context相关功能，可以在这里扩展
-	return g
+	return &GoZeroLogger{
+		logger: g.logger.WithContext(ctx),
+		skip:   g.skip,
+	}
 }
 
 // WithDuration 返回一个带持续时间字段的日志器
@@ -24,15 +24,18 @@ type gormLogger struct {
 	Logger
 	slowThreshold time.Duration // 慢查询耗时阈值
 	minLevels     map[string]zapcore.Level
-	skipMapMutex  sync.RWMutex // 保护skipMap的并发安全
+	level         zapcore.Level // LogMode设置的级别，只对当前gormLogger生效
+	skipMapMutex  sync.RWMutex  // 保护skipMap的并发安全
 }
 
 func NewGormLogger(logger Logger, slowThreshold time.Duration, minLevel map[string]zapcore.Level) logger2.Interface {
-	targetLogger := &gormLogger{Logger: logger, slowThreshold: slowThreshold, minLevels: minLevel}
+	targetLogger := &gormLogger{Logger: logger, slowThreshold: slowThreshold, minLevels: minLevel, level: zapcore.DebugLevel}
 	targetLogger.AutoSkip()
 	return targetLogger
 }
 
+// LogMode 返回使用指定级别的副本，不修改日志器的级别，其他共用该名称的日志器不受影响
+
 func (l *gormLogger) LogMode(level logger2.LogLevel) logger2.Interface {
 	var targetLevel zapcore.Level
 
@@ -47,27 +50,48 @@ func (l *gormLogger) LogMode(level logger2.LogLevel) logger2.Interface {
 		targetLevel = zapcore.PanicLevel
 	}
 
-	l.Logger = l.Logger.SetLevel(targetLevel)
+	return &gormLogger{Logger: l.Logger, slowThreshold: l.slowThreshold, minLevels: l.minLevels, level: targetLevel}
+}
 
-	return l
+// enabled LogMode设置的级别是否允许输出
+func (l *gormLogger) enabled(level zapcore.Level) bool {
+	return level >= l.level
 }
 
 // Info callbacks.go replace c.processor.db.gormLogger.Info(context.Background(), "replacing callback `%v` from %v\n", name, utils.FileWithLineNum())
-func (l gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
-	l.Logger.Info(fmt.Sprintf(msg, data...))
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if !l.enabled(zapcore.InfoLevel) {
+		return
+	}
+
+	l.Logger.WithContext(ctx).Info(fmt.Sprintf(msg, data...))
 }
 
-func (l gormLogger) Warn(ctx context.Context, s string, i ...interface{}) {
-	l.Logger.Warn(s, zap.Any(`值`, append([]interface{}{utils.FileWithLineNum()}, i...)))
+func (l *gormLogger) Warn(ctx context.Context, s string, i ...interface{}) {
+	if !l.enabled(zapcore.WarnLevel) {
+		return
+	}
+
+	l.Logger.WithContext(ctx).Warn(s, zap.Any(fieldNamesOf(l.Logger)[FieldValue], append([]interface{}{utils.FileWithLineNum()}, i...)))
 }
 
-func (l gormLogger) Error(ctx context.Context, s string, i ...interface{}) {
-	l.Logger.Error(s, zap.Any(`值`, append([]interface{}{utils.FileWithLineNum()}, i...)))
+func (l *gormLogger) Error(ctx context.Context, s string, i ...interface{}) {
+	if !l.enabled(zapcore.ErrorLevel) {
+		return
+	}
+
+	l.Logger.WithContext(ctx).Error(s, zap.Any(fieldNamesOf(l.Logger)[FieldValue], append([]interface{}{utils.FileWithLineNum()}, i...)))
 }
 
-func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if !l.enabled(zapcore.ErrorLevel) {
+		return
+	}
+
 	elapsed := time.Since(begin)
 	sql, rows := fc()
+	logger := l.Logger.WithContext(ctx)
+	names := fieldNamesOf(logger)
 	switch {
 	case err != nil:
 		value := ctx.Value(IgnoreErrorKey)
@@ -88,10 +112,18 @@ func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (strin
 			}
 		}
 
-		l.Logger.Error(`执行错误`, zap.String(`错误`, err.Error()), zap.Int64(`影响行数`, rows), zap.Duration(`耗时`, elapsed), zap.String(sqlField, sql))
+		logger.Error(`执行错误`, zap.String(names[FieldError], err.Error()), zap.Int64(names[FieldRows], rows), zap.Duration(names[FieldElapsed], elapsed), zap.String(names[FieldSQL], sql))
 	case elapsed > l.slowThreshold && l.slowThreshold != 0:
-		l.Logger.Warn(`慢查询`, zap.Duration(`阈值`, l.slowThreshold), zap.Int64(`影响行数`, rows), zap.Duration(`耗时`, elapsed), zap.String(sqlField, sql))
+		if !l.enabled(zapcore.WarnLevel) {
+			return
+		}
+
+		logger.Warn(`慢查询`, zap.Duration(names[FieldThreshold], l.slowThreshold), zap.Int64(names[FieldRows], rows), zap.Duration(names[FieldElapsed], elapsed), zap.String(names[FieldSQL], sql))
 	default:
+		if !l.enabled(zapcore.InfoLevel) {
+			return
+		}
+
 		value := ctx.Value(ModuleKey)
 		if value != nil {
 			if module, ok := value.(string); ok {
@@ -101,22 +133,21 @@ func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (strin
 
 				switch l.minLevels[module] {
 				case zapcore.DebugLevel, zapcore.InfoLevel:
-					l.Logger.Info(`执行成功`, zap.Int64(`影响行数`, rows), zap.Duration(`耗时`, elapsed), zap.String(sqlField, sql))
+					logger.Info(`执行成功`, zap.Int64(names[FieldRows], rows), zap.Duration(names[FieldElapsed], elapsed), zap.String(names[FieldSQL], sql))
 				}
 			}
 		}
 	}
 }
-func (l gormLogger) gormFields(msg string, data ...interface{}) []zap.Field {
+func (l *gormLogger) gormFields(msg string, data ...interface{}) []zap.Field {
 	return []zap.Field{
 		zap.String(`信息`, fmt.Sprintf(msg, data...)),
 	}
 }
 
 var (
-	sqlField = `SQL`
-	lineKey  = `gormLine`
-	fileKey  = `gormFile`
+	lineKey = `gormLine`
+	fileKey = `gormFile`
 )
 
 type CtxKey string
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	logger2 "gorm.io/gorm/logger"
@@ -23,6 +24,29 @@ var (
 	targetLogger logger2.Interface
 )
 
+// TestGormLogger_LogMode 测试LogMode只对返回的副本生效，不修改共用的级别
+func TestGormLogger_LogMode(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	root, err := (&Config{Service: "test", Level: zapcore.WarnLevel, HideConsole: true}).Build(core)
+	require.NoError(t, err)
+
+	gormLogger := NewGormLogger(root.Derive(`gorm`), time.Nanosecond, nil)
+	silent := gormLogger.LogMode(logger2.Silent)
+	gormLogger.LogMode(logger2.Info)
+
+	silent.Error(context.Background(), `错误`)
+	silent.Trace(context.Background(), time.Now().Add(-time.Second), func() (string, int64) {
+		return `SELECT 1`, 1
+	}, nil)
+	require.Equal(t, 0, recorded.Len(), `Silent的副本不输出`)
+
+	gormLogger.Warn(context.Background(), `警告`)
+	root.Info(`信息`)
+	require.Equal(t, 1, recorded.Len(), `原日志器不受影响`)
+	require.Equal(t, zapcore.WarnLevel, instanceOf(root).levels.Level(`gorm`), `共用的级别不变`)
+	require.Equal(t, zapcore.WarnLevel, instanceOf(root).levels.Level(``))
+}
+
 func TestNewLogger(t *testing.T) {
 	cfg := &Config{
 		Debug:   true,
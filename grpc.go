@@ -0,0 +1,256 @@
+package log2
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RequestIDMetadata 请求ID的元数据键
+	RequestIDMetadata = `x-request-id`
+)
+
+// GRPCOptions gRPC拦截器的选项
+type GRPCOptions struct {
+	MetadataKeys []string                            // 需要记录的元数据键，为空时不记录
+	LevelOf      func(code codes.Code) zapcore.Level // 按状态码决定级别，为空时使用DefaultGRPCLevel
+}
+
+func (o *GRPCOptions) levelOf(code codes.Code) zapcore.Level {
+	if o == nil || o.LevelOf == nil {
+		return DefaultGRPCLevel(code)
+	}
+
+	return o.LevelOf(code)
+}
+
+/*
+DefaultGRPCLevel 默认按状态码决定的级别，客户端原因导致的错误为Info，需要关注的为Warn，服务端错误为Error
+参数:
+*	code         	codes.Code   	状态码
+返回值:
+*	zapcore.Level	zapcore.Level	级别
+*/
+func DefaultGRPCLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return zapcore.InfoLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+/*
+GRPCUnaryServerInterceptor 生成记录一元调用的服务端拦截器
+每次调用以完整方法名衍生日志器，沿用元数据中的x-request-id作为任务ID，没有时调用Start生成，
+调用日志器通过IntoContext放入调用的context
+参数:
+*	target	Logger                        	日志器
+*	opts  	*GRPCOptions                  	选项，可以为nil
+返回值:
+*	grpc.UnaryServerInterceptor	grpc.UnaryServerInterceptor	拦截器
+*/
+func GRPCUnaryServerInterceptor(target Logger, opts *GRPCOptions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		callLogger := serverCallLogger(ctx, target, info.FullMethod, opts)
+
+		resp, err := handler(IntoContext(ctx, callLogger), req)
+		logGRPCCall(callLogger, `gRPC请求`, time.Since(start), err, opts)
+
+		return resp, err
+	}
+}
+
+/*
+GRPCStreamServerInterceptor 生成记录流式调用的服务端拦截器，在流结束时输出日志
+参数:
+*	target	Logger                         	日志器
+*	opts  	*GRPCOptions                   	选项，可以为nil
+返回值:
+*	grpc.StreamServerInterceptor	grpc.StreamServerInterceptor	拦截器
+*/
+func GRPCStreamServerInterceptor(target Logger, opts *GRPCOptions) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		callLogger := serverCallLogger(stream.Context(), target, info.FullMethod, opts)
+
+		err := handler(srv, &serverStream{ServerStream: stream, ctx: IntoContext(stream.Context(), callLogger)})
+		logGRPCCall(callLogger, `gRPC请求`, time.Since(start), err, opts)
+
+		return err
+	}
+}
+
+/*
+GRPCUnaryClientInterceptor 生成记录一元调用的客户端拦截器
+以context中的日志器(见WithContext)按完整方法名衍生日志器
+参数:
+*	target	Logger                        	日志器
+*	opts  	*GRPCOptions                  	选项，可以为nil
+返回值:
+*	grpc.UnaryClientInterceptor	grpc.UnaryClientInterceptor	拦截器
+*/
+func GRPCUnaryClientInterceptor(target Logger, opts *GRPCOptions) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		callLogger := clientCallLogger(ctx, target, method, cc, opts)
+
+		err := invoker(IntoContext(ctx, callLogger), method, req, reply, cc, callOpts...)
+		logGRPCCall(callLogger, `gRPC调用`, time.Since(start), err, opts)
+
+		return err
+	}
+}
+
+/*
+GRPCStreamClientInterceptor 生成记录流式调用的客户端拦截器，在接收到流结束或者错误时输出日志
+参数:
+*	target	Logger                         	日志器
+*	opts  	*GRPCOptions                   	选项，可以为nil
+返回值:
+*	grpc.StreamClientInterceptor	grpc.StreamClientInterceptor	拦截器
+*/
+func GRPCStreamClientInterceptor(target Logger, opts *GRPCOptions) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		start := time.Now()
+		callLogger := clientCallLogger(ctx, target, method, cc, opts)
+
+		stream, err := streamer(IntoContext(ctx, callLogger), desc, cc, method, callOpts...)
+		if err != nil {
+			logGRPCCall(callLogger, `gRPC调用`, time.Since(start), err, opts)
+
+			return nil, err
+		}
+
+		return &clientStream{ClientStream: stream, serverStreams: desc.ServerStreams, finish: func(err error) {
+			logGRPCCall(callLogger, `gRPC调用`, time.Since(start), err, opts)
+		}}, nil
+	}
+}
+
+// grpcLoggerName 方法对应的日志器名称，包名中的.替换为_，使其只占名称的一段，SetLevel及路由才能按名称控制
+func grpcLoggerName(method string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(method, `/`), `.`, `_`)
+}
+
+// serverCallLogger 生成服务端调用的日志器
+func serverCallLogger(ctx context.Context, target Logger, method string, opts *GRPCOptions) Logger {
+	callLogger := target.Derive(grpcLoggerName(method))
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	// 不合法时生成新的ID
+	var id string
+	if ids := md.Get(RequestIDMetadata); len(ids) > 0 && validRequestID(ids[0]) {
+		id = ids[0]
+	}
+
+	callLogger = callLogger.StartWithID(id)
+
+	names := fieldNamesOf(target)
+	fields := []zap.Field{zap.String(names[FieldRPCMethod], method)}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String(names[FieldPeer], p.Addr.String()))
+	}
+
+	return callLogger.With(append(fields, metadataFields(md, opts)...)...)
+}
+
+// clientCallLogger 生成客户端调用的日志器
+func clientCallLogger(ctx context.Context, target Logger, method string, cc *grpc.ClientConn, opts *GRPCOptions) Logger {
+	names := fieldNamesOf(target)
+	fields := []zap.Field{zap.String(names[FieldRPCMethod], method), zap.String(names[FieldPeer], cc.Target())}
+	md, _ := metadata.FromOutgoingContext(ctx)
+
+	return target.WithContext(ctx).Derive(grpcLoggerName(method)).With(append(fields, metadataFields(md, opts)...)...)
+}
+
+func metadataFields(md metadata.MD, opts *GRPCOptions) []zap.Field {
+	if opts == nil {
+		return nil
+	}
+
+	var fields []zap.Field
+
+	for _, key := range opts.MetadataKeys {
+		if values := md.Get(key); len(values) > 0 {
+			fields = append(fields, zap.Strings(strings.ToLower(key), values))
+		}
+	}
+
+	return fields
+}
+
+// logGRPCCall 按状态码的级别输出调用结果
+func logGRPCCall(callLogger Logger, msg string, elapsed time.Duration, err error, opts *GRPCOptions) {
+	code := status.Code(err)
+	names := fieldNamesOf(callLogger)
+	fields := []zap.Field{zap.String(names[FieldRPCCode], code.String()), zap.Duration(names[FieldElapsed], elapsed)}
+
+	if err != nil {
+		fields = append(fields, zap.String(names[FieldError], status.Convert(err).Message()))
+	}
+
+	switch opts.levelOf(code) {
+	case zapcore.DebugLevel:
+		callLogger.Debug(msg, fields...)
+	case zapcore.InfoLevel:
+		callLogger.Info(msg, fields...)
+	case zapcore.WarnLevel:
+		callLogger.Warn(msg, fields...)
+	default:
+		callLogger.Error(msg, fields...)
+	}
+}
+
+// serverStream 替换了context的服务端流
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientStream 在流结束时回调的客户端流
+type clientStream struct {
+	grpc.ClientStream
+	serverStreams bool // 服务端是否流式返回，否则收到唯一的响应即结束
+	once          sync.Once
+	finish        func(err error)
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+
+	switch {
+	case err == io.EOF: //nolint:errorlint
+		s.once.Do(func() { s.finish(nil) })
+	case err != nil:
+		s.once.Do(func() { s.finish(err) })
+	case !s.serverStreams:
+		s.once.Do(func() { s.finish(nil) })
+	}
+
+	return err
+}
@@ -0,0 +1,145 @@
+package log2
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestGRPCInterceptors 使用进程内的gRPC服务测试拦截器
+func TestGRPCInterceptors(t *testing.T) {
+	serverCore, serverRecorded := observer.New(zapcore.DebugLevel)
+	serverLogger, err := (&Config{Service: "server", Level: zapcore.DebugLevel, HideConsole: true}).Build(serverCore)
+	require.NoError(t, err)
+
+	clientCore, clientRecorded := observer.New(zapcore.DebugLevel)
+	clientLogger, err := (&Config{Service: "client", Level: zapcore.DebugLevel, HideConsole: true}).Build(clientCore)
+	require.NoError(t, err)
+
+	opts := &GRPCOptions{MetadataKeys: []string{`X-Tenant`}}
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(GRPCUnaryServerInterceptor(serverLogger, opts),
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				FromContext(ctx).Info(`处理中`)
+
+				return handler(ctx, req)
+			}),
+		grpc.ChainStreamInterceptor(GRPCStreamServerInterceptor(serverLogger, opts)),
+	)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(`passthrough:///bufnet`,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(GRPCUnaryClientInterceptor(clientLogger, opts)),
+		grpc.WithChainStreamInterceptor(GRPCStreamClientInterceptor(clientLogger, opts)),
+	)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	t.Run("一元调用", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIDMetadata, `req-1`, `x-tenant`, `t1`)
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		ctx = metadata.AppendToOutgoingContext(context.Background(), RequestIDMetadata, strings.Repeat(`a`, maxRequestIDLength+1))
+		_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: `missing`})
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		logs := serverRecorded.TakeAll()
+		require.Len(t, logs, 4)
+		require.Equal(t, `处理中`, logs[0].Message)
+		require.Equal(t, `req-1`, logs[0].ContextMap()[`任务ID`])
+
+		fields := logs[1].ContextMap()
+		require.Equal(t, `gRPC请求`, logs[1].Message)
+		require.Equal(t, zapcore.InfoLevel, logs[1].Level)
+		require.Equal(t, `grpc_health_v1_Health/Check`, logs[1].LoggerName)
+		require.Equal(t, `/grpc.health.v1.Health/Check`, fields[`方法`])
+		require.Equal(t, `req-1`, fields[`任务ID`])
+		require.Equal(t, []interface{}{`t1`}, fields[`x-tenant`])
+		require.Equal(t, `OK`, fields[`状态码`])
+		require.Contains(t, fields, `对端`)
+		require.Contains(t, fields, `耗时`)
+
+		require.NotEmpty(t, logs[3].ContextMap()[`任务ID`])
+		require.Len(t, logs[3].ContextMap()[`任务ID`], 24)
+		require.Equal(t, `NotFound`, logs[3].ContextMap()[`状态码`])
+		require.Equal(t, zapcore.InfoLevel, logs[3].Level)
+
+		logs = clientRecorded.TakeAll()
+		require.Len(t, logs, 2)
+		require.Equal(t, `gRPC调用`, logs[0].Message)
+		require.Equal(t, `OK`, logs[0].ContextMap()[`状态码`])
+		require.Equal(t, `passthrough:///bufnet`, logs[0].ContextMap()[`对端`])
+		require.Equal(t, `NotFound`, logs[1].ContextMap()[`状态码`])
+		require.Contains(t, logs[1].ContextMap(), `错误`)
+	})
+
+	t.Run("流式调用", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, 0, clientRecorded.Len())
+
+		cancel()
+
+		_, err = stream.Recv()
+		require.Equal(t, codes.Canceled, status.Code(err))
+
+		logs := clientRecorded.TakeAll()
+		require.Len(t, logs, 1)
+		require.Equal(t, `Canceled`, logs[0].ContextMap()[`状态码`])
+		require.Equal(t, `grpc_health_v1_Health/Watch`, logs[0].LoggerName)
+
+		require.Eventually(t, func() bool {
+			return serverRecorded.FilterMessage(`gRPC请求`).Len() == 1
+		}, time.Second, 5*time.Millisecond)
+		require.Equal(t, `/grpc.health.v1.Health/Watch`, serverRecorded.All()[0].ContextMap()[`方法`])
+		serverRecorded.TakeAll()
+	})
+
+	t.Run("按方法设置级别", func(t *testing.T) {
+		serverLogger.Derive(`grpc_health_v1_Health/Check`).SetLevel(zapcore.ErrorLevel)
+
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		require.Equal(t, 0, serverRecorded.Len(), `方法的Info日志被过滤`)
+
+		serverLogger.Derive(`grpc_health_v1_Health/Check`).SetLevel(zapcore.DebugLevel)
+
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		require.Equal(t, 2, serverRecorded.Len())
+	})
+}
@@ -0,0 +1,297 @@
+package log2
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// RequestIDHeader 请求ID的头
+	RequestIDHeader = `X-Request-ID`
+	// TraceParentHeader W3C Trace Context的头
+	TraceParentHeader = `traceparent`
+)
+
+// HTTPOptions HTTP中间件的选项
+type HTTPOptions struct {
+	SkipPaths []string                       // 不记录日志的路径，精确匹配，请求日志器依然会放入context
+	BodyLimit int                            // 记录请求体的最大字节数，为0时不记录
+	LevelOf   func(status int) zapcore.Level // 按状态码决定级别，为空时使用DefaultHTTPLevel
+	// TrustProxyHeaders 客户端IP是否使用X-Forwarded-For及X-Real-IP，只在部署于可信代理之后时开启，
+	// 否则客户端可以伪造，默认使用连接的对端地址
+	TrustProxyHeaders bool
+}
+
+/*
+DefaultHTTPLevel 默认按状态码决定的级别，5xx为Error，4xx为Warn，其余为Info
+参数:
+*	status       	int          	状态码
+返回值:
+*	zapcore.Level	zapcore.Level	级别
+*/
+func DefaultHTTPLevel(status int) zapcore.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return zapcore.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+/*
+HTTPMiddleware 生成记录请求日志的中间件
+每个请求沿用请求头中的X-Request-ID或traceparent的trace-id作为任务ID，没有时调用Start生成，
+请求日志器通过IntoContext放入请求的context，处理器中可以通过FromContext获取，
+处理器panic时记录错误并在未写入响应时返回500
+参数:
+*	target	Logger                        	日志器
+*	opts  	*HTTPOptions                  	选项，可以为nil
+返回值:
+*	func(http.Handler) http.Handler	func(http.Handler) http.Handler	中间件
+*/
+func HTTPMiddleware(target Logger, opts *HTTPOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = &HTTPOptions{}
+	}
+
+	levelOf := opts.LevelOf
+	if levelOf == nil {
+		levelOf = DefaultHTTPLevel
+	}
+
+	skipPaths := make(map[string]bool, len(opts.SkipPaths))
+	for _, path := range opts.SkipPaths {
+		skipPaths[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				start = time.Now()
+				id    = requestIDOf(r)
+			)
+
+			if id == `` {
+				id = newTaskID(target)
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+
+			requestLogger := target.StartWithID(id)
+
+			r = r.WithContext(IntoContext(r.Context(), requestLogger))
+
+			if skipPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			var body []byte
+			if opts.BodyLimit > 0 && r.Body != nil {
+				body = captureBody(r, opts.BodyLimit)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w}
+
+			defer func() {
+				recovered := recover()
+				if recovered == http.ErrAbortHandler { //nolint:errorlint
+					panic(recovered)
+				}
+
+				if recovered != nil && !recorder.wroteHeader {
+					recorder.WriteHeader(http.StatusInternalServerError)
+				}
+
+				names := fieldNamesOf(target)
+				fields := []zap.Field{
+					zap.String(names[FieldMethod], r.Method),
+					zap.String(names[FieldPath], r.URL.Path),
+					zap.Int(names[FieldStatus], recorder.statusCode()),
+					zap.Int64(names[FieldBytes], recorder.bytes),
+					zap.Duration(names[FieldElapsed], time.Since(start)),
+					zap.String(names[FieldClientIP], clientIP(r, opts.TrustProxyHeaders)),
+				}
+
+				if r.URL.RawQuery != `` {
+					fields = append(fields, zap.String(names[FieldQuery], r.URL.RawQuery))
+				}
+
+				if body != nil {
+					fields = append(fields, zap.ByteString(names[FieldBody], body))
+				}
+
+				if recovered != nil {
+					requestLogger.Error(`请求处理panic`, append(fields,
+						zap.NamedError(names[FieldError], errors.New(fmt.Sprint(recovered))), zap.Stack(names[FieldStack]))...)
+
+					return
+				}
+
+				switch levelOf(recorder.statusCode()) {
+				case zapcore.DebugLevel:
+					requestLogger.Debug(`HTTP请求`, fields...)
+				case zapcore.InfoLevel:
+					requestLogger.Info(`HTTP请求`, fields...)
+				case zapcore.WarnLevel:
+					requestLogger.Warn(`HTTP请求`, fields...)
+				default:
+					requestLogger.Error(`HTTP请求`, fields...)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// maxRequestIDLength 请求头中请求ID的最大长度
+const maxRequestIDLength = 128
+
+// requestIDOf 获取请求头中的请求ID，优先X-Request-ID，其次traceparent中的trace-id，不合法时返回空
+func requestIDOf(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+
+	// 格式为version-traceid-parentid-flags
+	parts := strings.Split(r.Header.Get(TraceParentHeader), `-`)
+	if len(parts) == 4 && len(parts[1]) == 32 && parts[1] != strings.Repeat(`0`, 32) && isHex(parts[1]) {
+		return parts[1]
+	}
+
+	return ``
+}
+
+// validRequestID 请求ID是否合法，只允许字母、数字及-_.:，长度不超过maxRequestIDLength
+func validRequestID(id string) bool {
+	if id == `` || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// isHex 是否全部为小写十六进制字符
+func isHex(text string) bool {
+	for _, c := range text {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// clientIP 获取客户端IP，trustProxy时优先使用代理设置的头
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get(`X-Forwarded-For`); forwarded != `` {
+			return strings.TrimSpace(strings.Split(forwarded, `,`)[0])
+		}
+
+		if realIP := r.Header.Get(`X-Real-IP`); realIP != `` {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// captureBody 读取最多limit字节的请求体，并保证处理器依然能读取完整的请求体
+func captureBody(r *http.Request, limit int) []byte {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, int64(limit)))
+
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+
+	return body
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseRecorder 记录状态码及写入字节数
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *responseRecorder) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.status = statusCode
+		w.wroteHeader = true
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+func (w *responseRecorder) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// Flush 支持流式响应
+func (w *responseRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack 支持websocket等协议升级
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New(`ResponseWriter不支持Hijack`)
+	}
+
+	return hijacker.Hijack()
+}
+
+// Unwrap 供http.ResponseController使用
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
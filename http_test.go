@@ -0,0 +1,134 @@
+package log2
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestHTTPMiddleware 测试请求日志中间件
+func TestHTTPMiddleware(t *testing.T) {
+	cfg := &Config{Service: "test", Level: zapcore.DebugLevel, HideConsole: true}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(`/ok`, func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		FromContext(r.Context()).Info(`处理中`)
+		_, _ = w.Write(data)
+	})
+	mux.HandleFunc(`/missing`, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc(`/panic`, func(w http.ResponseWriter, r *http.Request) {
+		panic(`出错了`)
+	})
+	mux.HandleFunc(`/health`, func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info(`健康检查`)
+	})
+
+	handler := HTTPMiddleware(root.Derive(`http`), &HTTPOptions{SkipPaths: []string{`/health`}, BodyLimit: 4, TrustProxyHeaders: true})
+
+	serve := func(method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		for key, value := range header {
+			request.Header.Set(key, value)
+		}
+
+		response := httptest.NewRecorder()
+		handler(mux).ServeHTTP(response, request)
+
+		return response
+	}
+
+	t.Run("沿用请求ID并记录请求体", func(t *testing.T) {
+		response := serve(http.MethodPost, `/ok?a=1`, `123456`, map[string]string{RequestIDHeader: `req-1`, `X-Forwarded-For`: `1.1.1.1, 2.2.2.2`})
+		require.Equal(t, `123456`, response.Body.String())
+		require.Equal(t, `req-1`, response.Header().Get(RequestIDHeader))
+
+		logs := recorded.TakeAll()
+		require.Len(t, logs, 2)
+		require.Equal(t, `req-1`, logs[0].ContextMap()[`任务ID`])
+
+		fields := logs[1].ContextMap()
+		require.Equal(t, zapcore.InfoLevel, logs[1].Level)
+		require.Equal(t, `http`, logs[1].LoggerName)
+		require.Equal(t, `req-1`, fields[`任务ID`])
+		require.Equal(t, `POST`, fields[`方法`])
+		require.Equal(t, `/ok`, fields[`路径`])
+		require.Equal(t, `a=1`, fields[`参数`])
+		require.EqualValues(t, http.StatusOK, fields[`状态码`])
+		require.EqualValues(t, 6, fields[`字节数`])
+		require.Equal(t, `1.1.1.1`, fields[`客户端IP`])
+		require.Equal(t, `1234`, fields[`请求体`])
+		require.Contains(t, fields, `耗时`)
+	})
+
+	t.Run("traceparent及生成任务ID", func(t *testing.T) {
+		serve(http.MethodGet, `/ok`, ``, map[string]string{TraceParentHeader: `00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01`})
+		response := serve(http.MethodGet, `/ok`, ``, nil)
+
+		logs := recorded.TakeAll()
+		require.Len(t, logs, 4)
+		require.Equal(t, `4bf92f3577b34da6a3ce929d0e0e4736`, logs[1].ContextMap()[`任务ID`])
+		require.Equal(t, logs[3].ContextMap()[`任务ID`], response.Header().Get(RequestIDHeader), `生成的ID返回给客户端`)
+		require.NotEmpty(t, logs[3].ContextMap()[`任务ID`])
+		require.Equal(t, logs[2].ContextMap()[`任务ID`], logs[3].ContextMap()[`任务ID`])
+		require.Equal(t, `192.0.2.1`, logs[3].ContextMap()[`客户端IP`])
+	})
+
+	t.Run("不合法的请求ID", func(t *testing.T) {
+		for _, id := range []string{strings.Repeat(`a`, maxRequestIDLength+1), "req\n伪造", `<script>`} {
+			response := serve(http.MethodGet, `/ok`, ``, map[string]string{RequestIDHeader: id})
+
+			logs := recorded.TakeAll()
+			require.Len(t, logs, 2)
+			used := response.Header().Get(RequestIDHeader)
+			require.NotEqual(t, id, used)
+			require.Len(t, used, 24, `生成新的ID`)
+			require.Equal(t, used, logs[1].ContextMap()[`任务ID`])
+		}
+	})
+
+	t.Run("按状态码决定级别及panic", func(t *testing.T) {
+		serve(http.MethodGet, `/missing`, ``, nil)
+		response := serve(http.MethodGet, `/panic`, ``, nil)
+		require.Equal(t, http.StatusInternalServerError, response.Code)
+
+		logs := recorded.TakeAll()
+		require.Len(t, logs, 2)
+		require.Equal(t, zapcore.WarnLevel, logs[0].Level)
+		require.EqualValues(t, http.StatusNotFound, logs[0].ContextMap()[`状态码`])
+		require.Equal(t, zapcore.ErrorLevel, logs[1].Level)
+		require.Equal(t, `出错了`, logs[1].ContextMap()[`错误`])
+		require.Contains(t, logs[1].ContextMap(), `堆栈`)
+	})
+
+	t.Run("跳过路径", func(t *testing.T) {
+		serve(http.MethodGet, `/health`, ``, nil)
+
+		logs := recorded.TakeAll()
+		require.Len(t, logs, 1)
+		require.Equal(t, `健康检查`, logs[0].Message)
+	})
+
+	t.Run("默认不信任代理头", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, `/ok`, nil)
+		request.Header.Set(`X-Forwarded-For`, `1.1.1.1`)
+		request.Header.Set(`X-Real-IP`, `2.2.2.2`)
+		HTTPMiddleware(root, nil)(mux).ServeHTTP(httptest.NewRecorder(), request)
+
+		logs := recorded.TakeAll()
+		require.Len(t, logs, 2)
+		require.Equal(t, `192.0.2.1`, logs[1].ContextMap()[`客户端IP`])
+	})
+}
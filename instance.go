@@ -0,0 +1,246 @@
+package log2
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// instance Config.Build构建出的日志器实例，同一棵日志器树共享，不同实例之间互不影响
+type instance struct {
+	lock       sync.Mutex             // 保护重新加载配置
+	config     *Config                // 当前生效的配置
+	inputCores []zapcore.Core         // Build时传入的额外core，重新加载时保留
+	output     atomic.Pointer[output] // 当前的输出
+	levels     *levelRegistry         // 级别表
+}
+
+// instanceOf 获取日志器所属的实例，不是本包的日志器时返回nil
+func instanceOf(target Logger) *instance {
+	if result, ok := target.(*logger); ok {
+		return result.instance
+	}
+
+	return nil
+}
+
+// built 是否通过Config.Build构建
+func (i *instance) built() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return i.config != nil
+}
+
+// output 按配置构建出的一组输出
+type output struct {
+	encoder      zapcore.Encoder   // 所有输出共用的编码器
+	core         zapcore.Core      // 包含所有输出的core
+	closers      []io.Closer       // 需要关闭的文件等资源，按添加的相反顺序关闭
+	asyncWriters []*asyncWriter    // 异步写入的writer
+	trace        *TraceConfig      // 链路关联配置
+	taskID       *TaskIDConfig     // 任务ID配置
+	fieldNames   map[string]string // 内置字段的名称
+	files        []retentionSource // 写入的日志文件，用于磁盘配额检查
+	busy         *busyFiles        // 正在压缩或者等待上传的文件
+	uploads      *fileBuilder      // 切分出的文件的上传，通过startUploads启动
+}
+
+// startUploads 启动上传，handover为旧输出未上传的文件，为空时补传所有未上传的文件
+func (o *output) startUploads(handover map[string]bool) {
+	if o.uploads != nil {
+		o.uploads.start(handover)
+	}
+}
+
+// pendingUploads 关闭后仍未上传的文件，没有上传时为空，新的上传会补传所有未上传的文件
+func (o *output) pendingUploads() map[string]bool {
+	if o.uploads == nil {
+		return nil
+	}
+
+	return o.uploads.pendingFiles()
+}
+
+func (o *output) close() error {
+	err := o.core.Sync()
+
+	for i := len(o.closers) - 1; i >= 0; i-- {
+		err = multierr.Append(err, o.closers[i].Close())
+	}
+
+	return err
+}
+
+/*
+reload 使用新配置重建输出并替换，旧输出在替换后同步并关闭
+不在配置文件中的Hooks、切分回调及任务ID生成函数未配置时沿用当前配置，按名称设置的级别保留
+参数:
+*	config  	*Config	新配置
+*	levelSet	bool   	新配置是否设置了级别，未设置或者与当前配置相同时保留运行时设置的根级别
+返回值:
+*	error   	error  	错误
+*/
+func (i *instance) reload(config *Config, levelSet bool) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.config == nil {
+		return errors.New(`日志器未通过Config.Build构建,不支持重新加载配置`)
+	}
+
+	inheritCallbacks(config, i.config)
+
+	out, err := config.buildOutput(i.inputCores...)
+	if err != nil {
+		return errors.Wrap(err, `构建输出`)
+	}
+
+	old := i.output.Swap(out)
+
+	if levelSet && config.Level != i.config.Level {
+		i.levels.SetLevel(``, config.Level)
+	} else {
+		config.Level = i.config.Level
+	}
+
+	i.config = config
+
+	if old == nil {
+		out.startUploads(nil)
+
+		return nil
+	}
+
+	// 已经取得旧输出的日志仍会写入，lumberjack关闭后再次写入会重新打开文件，不会丢失
+	err = old.close()
+
+	// 旧输出未上传的文件交给新的上传，不重新扫描，避免同一个文件被上传两次
+	out.startUploads(old.pendingUploads())
+
+	return errors.Wrap(err, `关闭旧输出`)
+}
+
+/*
+inheritCallbacks 新配置中未设置的Hooks、切分回调及任务ID生成函数沿用当前配置，这些字段无法写在配置文件中
+参数:
+*	config 	*Config	新配置
+*	current	*Config	当前配置
+*/
+func inheritCallbacks(config, current *Config) {
+	if config.Hooks == nil {
+		config.Hooks = current.Hooks
+	}
+
+	if current.Rotate != nil && current.Rotate.OnRotate != nil {
+		if config.Rotate == nil {
+			config.Rotate = &RotateConfig{}
+		}
+
+		if config.Rotate.OnRotate == nil {
+			config.Rotate.OnRotate = current.Rotate.OnRotate
+		}
+	}
+
+	if current.TaskID != nil && current.TaskID.New != nil {
+		if config.TaskID == nil {
+			config.TaskID = &TaskIDConfig{}
+		}
+
+		if config.TaskID.New == nil {
+			config.TaskID.New = current.TaskID.New
+		}
+	}
+
+	// 路由按文件路径对应，只有新旧路由都有单独的切分配置时沿用
+	onRotates := make(map[string]func(string), len(current.Routes))
+
+	for _, route := range current.Routes {
+		if route.Rotate != nil && route.Rotate.OnRotate != nil {
+			onRotates[filepath.Clean(route.Path)] = route.Rotate.OnRotate
+		}
+	}
+
+	for i := range config.Routes {
+		route := &config.Routes[i]
+
+		if onRotate := onRotates[filepath.Clean(route.Path)]; onRotate != nil && route.Rotate != nil && route.Rotate.OnRotate == nil {
+			route.Rotate.OnRotate = onRotate
+		}
+	}
+}
+
+// sync 同步当前输出
+func (i *instance) sync() error {
+	return errors.Wrap(i.output.Load().core.Sync(), `同步`)
+}
+
+// close 同步并关闭当前输出
+func (i *instance) close() error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return errors.Wrap(i.output.Load().close(), `关闭`)
+}
+
+// dynamicCore 始终写入实例当前输出的core，重新加载配置后已有日志器立即使用新输出
+type dynamicCore struct {
+	instance *instance
+	fields   []zapcore.Field
+	cache    *atomic.Pointer[dynamicCache]
+}
+
+// dynamicCache 添加了字段的core缓存，输出变化后重建
+type dynamicCache struct {
+	output *output
+	core   zapcore.Core
+}
+
+func newDynamicCore(target *instance) zapcore.Core {
+	return dynamicCore{instance: target, cache: &atomic.Pointer[dynamicCache]{}}
+}
+
+func (c dynamicCore) current() zapcore.Core {
+	out := c.instance.output.Load()
+	if len(c.fields) == 0 {
+		return out.core
+	}
+
+	if cached := c.cache.Load(); cached != nil && cached.output == out {
+		return cached.core
+	}
+
+	cached := &dynamicCache{output: out, core: out.core.With(c.fields)}
+	c.cache.Store(cached)
+
+	return cached.core
+}
+
+func (c dynamicCore) Enabled(level zapcore.Level) bool {
+	return c.current().Enabled(level)
+}
+
+func (c dynamicCore) With(fields []zapcore.Field) zapcore.Core {
+	merged := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	merged = append(merged, c.fields...)
+	merged = append(merged, fields...)
+
+	return dynamicCore{instance: c.instance, fields: merged, cache: &atomic.Pointer[dynamicCache]{}}
+}
+
+func (c dynamicCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.current().Check(entry, checked)
+}
+
+func (c dynamicCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(entry, fields)
+}
+
+func (c dynamicCore) Sync() error {
+	return c.current().Sync()
+}
@@ -0,0 +1,211 @@
+package log2
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	// anyLevel 允许所有级别，实际级别由levelCore统一控制
+	anyLevel = zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })
+)
+
+// levelRegistry 同一棵日志器树共享的级别表，包括根级别和按Derive名称设置的级别
+// 级别保存在不可变的快照中，修改时复制后整体替换，写日志时只需一次原子读取
+type levelRegistry struct {
+	levels  atomic.Pointer[levelSnapshot]
+	lock    sync.RWMutex        // 串行修改级别，保护derived
+	derived map[string]struct{} // 通过Derive衍生过的名称
+}
+
+// levelSnapshot 某一时刻的级别，创建后不再修改
+type levelSnapshot struct {
+	root  zapcore.Level
+	min   zapcore.Level // 根级别及所有名称中最低的级别
+	names map[string]zapcore.Level
+}
+
+/*
+level 获取名称对应的生效级别，未单独设置的名称逐级向上查找父名称，都未设置时使用根级别
+参数:
+*	name         	string       	日志器名称，多级名称以.分隔
+返回值:
+*	zapcore.Level	zapcore.Level	生效级别
+*/
+func (s *levelSnapshot) level(name string) zapcore.Level {
+	if len(s.names) == 0 {
+		return s.root
+	}
+
+	for name != `` {
+		if level, exist := s.names[name]; exist {
+			return level
+		}
+
+		index := strings.LastIndexByte(name, '.')
+		if index < 0 {
+			break
+		}
+
+		name = name[:index]
+	}
+
+	return s.root
+}
+
+func newLevelRegistry(level zapcore.Level) *levelRegistry {
+	result := &levelRegistry{derived: make(map[string]struct{})}
+	result.levels.Store(&levelSnapshot{root: level, min: level})
+
+	return result
+}
+
+// register 记录衍生出的名称
+func (r *levelRegistry) register(name string) {
+	r.lock.RLock()
+	_, exist := r.derived[name]
+	r.lock.RUnlock()
+
+	if exist {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.derived[name] = struct{}{}
+}
+
+// loggerLevel 名称及其级别
+type loggerLevel struct {
+	Name     string `json:"name"`
+	Level    string `json:"level"`
+	Explicit bool   `json:"explicit"` // 是否单独设置过级别，否则继承自父名称
+}
+
+/*
+Loggers 获取所有衍生过或单独设置过级别的名称及生效级别
+返回值:
+*	[]loggerLevel	[]loggerLevel	按名称排序的结果
+*/
+func (r *levelRegistry) Loggers() []loggerLevel {
+	snapshot := r.levels.Load()
+
+	r.lock.RLock()
+	names := make([]string, 0, len(r.derived)+len(snapshot.names))
+
+	for name := range r.derived {
+		names = append(names, name)
+	}
+
+	for name := range snapshot.names {
+		if _, exist := r.derived[name]; !exist {
+			names = append(names, name)
+		}
+	}
+	r.lock.RUnlock()
+
+	sort.Strings(names)
+
+	result := make([]loggerLevel, 0, len(names))
+
+	for _, name := range names {
+		_, explicit := snapshot.names[name]
+
+		result = append(result, loggerLevel{
+			Name:     name,
+			Level:    snapshot.level(name).String(),
+			Explicit: explicit,
+		})
+	}
+
+	return result
+}
+
+/*
+Level 获取名称对应的生效级别
+未单独设置的名称逐级向上查找父名称，都未设置时使用根级别
+参数:
+*	name         	string       	日志器名称，多级名称以.分隔
+返回值:
+*	zapcore.Level	zapcore.Level	生效级别
+*/
+func (r *levelRegistry) Level(name string) zapcore.Level {
+	return r.levels.Load().level(name)
+}
+
+/*
+SetLevel 设置名称对应的级别，名称为空时设置根级别
+参数:
+*	name 	string       	日志器名称
+*	level	zapcore.Level	级别
+*/
+func (r *levelRegistry) SetLevel(name string, level zapcore.Level) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	old := r.levels.Load()
+	snapshot := &levelSnapshot{root: old.root, names: old.names}
+
+	if name == `` {
+		snapshot.root = level
+	} else {
+		snapshot.names = make(map[string]zapcore.Level, len(old.names)+1)
+
+		for key, value := range old.names {
+			snapshot.names[key] = value
+		}
+
+		snapshot.names[name] = level
+	}
+
+	snapshot.min = snapshot.root
+
+	for _, value := range snapshot.names {
+		if value < snapshot.min {
+			snapshot.min = value
+		}
+	}
+
+	r.levels.Store(snapshot)
+}
+
+// minLevel 所有已设置级别中最低的级别
+func (r *levelRegistry) minLevel() zapcore.Level {
+	return r.levels.Load().min
+}
+
+// levelCore 按日志器名称从levelRegistry中取生效级别进行过滤的core
+type levelCore struct {
+	zapcore.Core
+	levels *levelRegistry
+}
+
+func newLevelCore(core zapcore.Core, levels *levelRegistry) zapcore.Core {
+	return levelCore{Core: core, levels: levels}
+}
+
+func (c levelCore) Level() zapcore.Level {
+	return c.levels.minLevel()
+}
+
+func (c levelCore) Enabled(level zapcore.Level) bool {
+	return level >= c.levels.minLevel() && c.Core.Enabled(level)
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level < c.levels.Level(entry.LoggerName) {
+		return checked
+	}
+
+	return c.Core.Check(entry, checked)
+}
@@ -0,0 +1,131 @@
+package log2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelHandler 查看及修改日志器树级别的http处理器
+type levelHandler struct {
+	logger Logger
+}
+
+// levelRequest 修改级别的请求
+type levelRequest struct {
+	Name  string `json:"name"`  // 名称，为空时修改根级别
+	Level string `json:"level"` // 级别，分别为debug,info,warn,error,dpanic,panic,fatal
+}
+
+// levelResponse 级别查询结果
+type levelResponse struct {
+	Level   string        `json:"level"`   // 根级别
+	Loggers []loggerLevel `json:"loggers"` // 衍生日志器的生效级别
+}
+
+// levelErrorResponse 错误返回
+type levelErrorResponse struct {
+	Error string `json:"error"`
+}
+
+/*
+LevelHandler 新建运行时查看及修改级别的http处理器
+GET 返回根级别及所有通过Derive衍生出的名称的生效级别
+PUT/POST 修改级别，支持json({"name":"mysql","level":"debug"})及表单/查询参数(name=mysql&level=debug)，name为空时修改根级别
+参数:
+*	logger	Logger      	通过Config.Build构建的日志器
+返回值:
+*	http.Handler	http.Handler	处理器
+*/
+func LevelHandler(logger Logger) http.Handler {
+	return &levelHandler{logger: logger}
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		request, err := decodeLevelRequest(r)
+		if err != nil {
+			h.writeJSON(w, http.StatusBadRequest, levelErrorResponse{Error: err.Error()})
+
+			return
+		}
+
+		if err = h.setLevel(request); err != nil {
+			h.writeJSON(w, http.StatusBadRequest, levelErrorResponse{Error: err.Error()})
+
+			return
+		}
+	default:
+		h.writeJSON(w, http.StatusMethodNotAllowed, levelErrorResponse{
+			Error: fmt.Sprintf(`仅支持GET,PUT,POST,不支持[%s]`, r.Method),
+		})
+
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, h.current())
+}
+
+func (h *levelHandler) levels() *levelRegistry {
+	if target := instanceOf(h.logger); target != nil {
+		return target.levels
+	}
+
+	return nil
+}
+
+func (h *levelHandler) current() levelResponse {
+	result := levelResponse{Level: h.logger.Level().String(), Loggers: []loggerLevel{}}
+
+	if levels := h.levels(); levels != nil {
+		result.Level = levels.Level(``).String()
+		result.Loggers = levels.Loggers()
+	}
+
+	return result
+}
+
+func (h *levelHandler) setLevel(request levelRequest) error {
+	var level zapcore.Level
+
+	if err := level.UnmarshalText([]byte(request.Level)); err != nil || request.Level == `` {
+		return errors.Errorf(`无效的级别[%s]`, request.Level)
+	}
+
+	levels := h.levels()
+	if levels == nil {
+		return errors.New(`日志器未通过Config.Build构建,不支持修改级别`)
+	}
+
+	levels.SetLevel(request.Name, level)
+
+	return nil
+}
+
+func (h *levelHandler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set(`Content-Type`, `application/json`)
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func decodeLevelRequest(r *http.Request) (request levelRequest, err error) {
+	if strings.HasPrefix(r.Header.Get(`Content-Type`), `application/json`) {
+		if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return request, errors.Wrap(err, `解析请求`)
+		}
+
+		return request, nil
+	}
+
+	request.Name = r.FormValue(`name`)
+	request.Level = r.FormValue(`level`)
+
+	return request, nil
+}
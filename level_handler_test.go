@@ -0,0 +1,97 @@
+package log2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func serveLevel(t *testing.T, handler http.Handler, method, contentType, body string) (int, levelResponse) {
+	request := httptest.NewRequest(method, `/log/level`, strings.NewReader(body))
+	if contentType != `` {
+		request.Header.Set(`Content-Type`, contentType)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	var response levelResponse
+	if recorder.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	}
+
+	return recorder.Code, response
+}
+
+// TestLevelHandler 测试级别http处理器
+func TestLevelHandler(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := (&Config{Service: "test", Level: zapcore.InfoLevel, HideConsole: true}).Build(core)
+	require.NoError(t, err)
+
+	mysql := root.Derive(`mysql`)
+	slow := mysql.Derive(`slow`)
+	handler := LevelHandler(root)
+
+	t.Run("查看", func(t *testing.T) {
+		code, response := serveLevel(t, handler, http.MethodGet, ``, ``)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `info`, response.Level)
+		require.Equal(t, []loggerLevel{
+			{Name: `mysql`, Level: `info`},
+			{Name: `mysql.slow`, Level: `info`},
+		}, response.Loggers)
+	})
+
+	t.Run("json修改衍生级别", func(t *testing.T) {
+		code, response := serveLevel(t, handler, http.MethodPut, `application/json`, `{"name":"mysql","level":"debug"}`)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `info`, response.Level)
+		require.Equal(t, loggerLevel{Name: `mysql`, Level: `debug`, Explicit: true}, response.Loggers[0])
+		require.Equal(t, loggerLevel{Name: `mysql.slow`, Level: `debug`}, response.Loggers[1])
+
+		slow.Debug(`可见`)
+		root.Debug(`不可见`)
+		require.Equal(t, 1, recorded.Len())
+	})
+
+	t.Run("表单修改根级别", func(t *testing.T) {
+		form := url.Values{`level`: []string{`error`}}
+		code, response := serveLevel(t, handler, http.MethodPost, `application/x-www-form-urlencoded`, form.Encode())
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `error`, response.Level)
+		require.Equal(t, zapcore.ErrorLevel, root.Level())
+	})
+
+	t.Run("错误请求", func(t *testing.T) {
+		code, _ := serveLevel(t, handler, http.MethodPut, `application/json`, `{"level":"verbose"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = serveLevel(t, handler, http.MethodPut, `application/json`, `{`)
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = serveLevel(t, handler, http.MethodDelete, ``, ``)
+		require.Equal(t, http.StatusMethodNotAllowed, code)
+	})
+
+	t.Run("未通过Build构建", func(t *testing.T) {
+		plain := NewLogger(zap.New(core), "plain", 0, false, false, nil, nil)
+		plainHandler := LevelHandler(plain)
+
+		code, response := serveLevel(t, plainHandler, http.MethodGet, ``, ``)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, `debug`, response.Level)
+
+		code, _ = serveLevel(t, plainHandler, http.MethodPut, `application/json`, `{"level":"info"}`)
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+}
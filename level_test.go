@@ -0,0 +1,169 @@
+package log2
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// bufferHook 写入内存的Hook，用于测试
+type bufferHook struct {
+	lock     sync.Mutex
+	buffer   bytes.Buffer
+	minLevel zapcore.Level
+}
+
+func (h *bufferHook) Write(p []byte) (int, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.buffer.Write(p)
+}
+
+func (h *bufferHook) Writer() io.Writer {
+	return h
+}
+
+func (h *bufferHook) MinLevel() zapcore.Level {
+	return h.minLevel
+}
+
+func (h *bufferHook) String() string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.buffer.String()
+}
+
+// TestLevelRegistry 测试级别表的继承查找
+func TestLevelRegistry(t *testing.T) {
+	levels := newLevelRegistry(zapcore.InfoLevel)
+
+	require.Equal(t, zapcore.InfoLevel, levels.Level(``))
+	require.Equal(t, zapcore.InfoLevel, levels.Level(`a.b`))
+
+	levels.SetLevel(`a`, zapcore.DebugLevel)
+	require.Equal(t, zapcore.DebugLevel, levels.Level(`a`))
+	require.Equal(t, zapcore.DebugLevel, levels.Level(`a.b.c`))
+	require.Equal(t, zapcore.InfoLevel, levels.Level(`ab`))
+	require.Equal(t, zapcore.DebugLevel, levels.minLevel())
+
+	levels.SetLevel(`a.b`, zapcore.ErrorLevel)
+	require.Equal(t, zapcore.ErrorLevel, levels.Level(`a.b.c`))
+
+	levels.SetLevel(``, zapcore.WarnLevel)
+	require.Equal(t, zapcore.WarnLevel, levels.Level(`c`))
+
+	levels.SetLevel(`a`, zapcore.ErrorLevel)
+	require.Equal(t, zapcore.WarnLevel, levels.minLevel(), `调高级别后重新计算最低级别`)
+}
+
+// TestLogger_SetLevelKeepSinks 测试调整级别后保留Hook等所有输出
+func TestLogger_SetLevelKeepSinks(t *testing.T) {
+	hook := &bufferHook{minLevel: zapcore.DebugLevel}
+	cfg := &Config{
+		Service:     "test",
+		Level:       zapcore.InfoLevel,
+		HideConsole: true,
+		Hooks:       []Hook{hook},
+	}
+
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	root.Debug(`不可见`)
+	require.Equal(t, 0, recorded.Len())
+	require.Empty(t, hook.String())
+
+	require.Equal(t, root, root.SetLevel(zapcore.DebugLevel))
+	require.Equal(t, zapcore.DebugLevel, root.Level())
+
+	root.Debug(`可见`)
+	require.Equal(t, 1, recorded.Len())
+	require.Contains(t, hook.String(), `可见`)
+
+	root.SetLevel(zapcore.WarnLevel)
+	root.Info(`不可见`)
+	require.Equal(t, 1, recorded.Len())
+}
+
+// TestLogger_SetLevelDerived 测试按衍生名称设置级别
+func TestLogger_SetLevelDerived(t *testing.T) {
+	cfg := &Config{Service: "test", Level: zapcore.InfoLevel, HideConsole: true}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	mysql := root.Derive(`mysql`)
+	slow := mysql.Derive(`slow`)
+	other := root.Derive(`other`)
+
+	mysql.SetLevel(zapcore.DebugLevel)
+
+	mysql.Debug(`mysql`)
+	slow.Debug(`slow`)
+	other.Debug(`other`)
+	root.Debug(`root`)
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 2)
+	require.Equal(t, `mysql.slow`, logs[1].LoggerName)
+
+	// 父级别调整后，未单独设置的子日志器跟随
+	root.SetLevel(zapcore.ErrorLevel)
+	other.Warn(`other`)
+	mysql.Warn(`mysql`)
+	require.Equal(t, 1, recorded.Len())
+	require.Equal(t, zapcore.ErrorLevel, other.Level())
+	require.Equal(t, zapcore.DebugLevel, slow.With(zap.String(`k`, `v`)).Level())
+}
+
+// TestLogger_SetLevelConcurrent 测试并发调整级别
+func TestLogger_SetLevelConcurrent(t *testing.T) {
+	cfg := &Config{Service: "test", Level: zapcore.InfoLevel, HideConsole: true}
+	core, _ := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	var group sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		group.Add(1)
+
+		go func(i int) {
+			defer group.Done()
+
+			logger := root.Derive(strings.Repeat(`a`, i%3+1))
+			for j := 0; j < 100; j++ {
+				logger.SetLevel(zapcore.Level(j%4 - 1))
+				logger.Info(`并发`)
+			}
+		}(i)
+	}
+
+	group.Wait()
+}
+
+// TestLogger_SetLevelWithoutBuild 测试未通过Build构建的日志器设置级别
+func TestLogger_SetLevelWithoutBuild(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	logger := NewLogger(zap.New(core), "test", 0, false, false, nil, nil)
+
+	warnLogger := logger.SetLevel(zapcore.WarnLevel)
+	warnLogger.Info(`不可见`)
+	warnLogger.Warn(`可见`)
+
+	require.Equal(t, 1, recorded.Len())
+	require.Equal(t, zapcore.WarnLevel, warnLogger.Level())
+}
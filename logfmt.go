@@ -0,0 +1,488 @@
+package log2
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// 输出格式
+const (
+	// FormatConsole 控制台格式，以tab分隔
+	FormatConsole = `console`
+	// FormatJSON 每行一个JSON对象
+	FormatJSON = `json`
+	// FormatLogfmt 每行若干key=value
+	FormatLogfmt = `logfmt`
+)
+
+var (
+	logfmtPool = buffer.NewPool()
+)
+
+// logfmtEncoder logfmt格式的编码器，嵌套对象以.连接键名展开，数组输出为[a,b]
+type logfmtEncoder struct {
+	*zapcore.EncoderConfig
+	buf    *buffer.Buffer // With添加的字段
+	prefix string         // 当前命名空间及对象的键名前缀
+}
+
+/*
+NewLogfmtEncoder 生成logfmt格式的编码器，值包含空格、=、"或者控制字符时加引号并转义
+参数:
+*	config         	zapcore.EncoderConfig	编码器配置，与JSON、console编码器一致
+返回值:
+*	zapcore.Encoder	zapcore.Encoder      	编码器
+*/
+func NewLogfmtEncoder(config zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{EncoderConfig: &config, buf: logfmtPool.Get()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := &logfmtEncoder{EncoderConfig: e.EncoderConfig, buf: logfmtPool.Get(), prefix: e.prefix}
+	_, _ = clone.buf.Write(e.buf.Bytes())
+
+	return clone
+}
+
+func (e *logfmtEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := logfmtPool.Get()
+
+	if e.TimeKey != `` {
+		if e.EncodeTime != nil {
+			e.appendEncoded(final, e.TimeKey, func(enc zapcore.PrimitiveArrayEncoder) { e.EncodeTime(entry.Time, enc) })
+		} else {
+			appendLogfmt(final, e.TimeKey, entry.Time.Format(time.RFC3339Nano))
+		}
+	}
+
+	if e.LevelKey != `` && e.EncodeLevel != nil {
+		e.appendEncoded(final, e.LevelKey, func(enc zapcore.PrimitiveArrayEncoder) { e.EncodeLevel(entry.Level, enc) })
+	}
+
+	if e.NameKey != `` && entry.LoggerName != `` {
+		if e.EncodeName != nil {
+			e.appendEncoded(final, e.NameKey, func(enc zapcore.PrimitiveArrayEncoder) { e.EncodeName(entry.LoggerName, enc) })
+		} else {
+			appendLogfmt(final, e.NameKey, entry.LoggerName)
+		}
+	}
+
+	if entry.Caller.Defined {
+		if e.CallerKey != `` && e.EncodeCaller != nil {
+			e.appendEncoded(final, e.CallerKey, func(enc zapcore.PrimitiveArrayEncoder) { e.EncodeCaller(entry.Caller, enc) })
+		}
+
+		if e.FunctionKey != `` {
+			appendLogfmt(final, e.FunctionKey, entry.Caller.Function)
+		}
+	}
+
+	if e.MessageKey != `` {
+		appendLogfmt(final, e.MessageKey, entry.Message)
+	}
+
+	if e.buf.Len() > 0 {
+		if final.Len() > 0 {
+			final.AppendByte(' ')
+		}
+
+		_, _ = final.Write(e.buf.Bytes())
+	}
+
+	if len(fields) > 0 {
+		fieldsEncoder := &logfmtEncoder{EncoderConfig: e.EncoderConfig, buf: final, prefix: e.prefix}
+		for i := range fields {
+			fields[i].AddTo(fieldsEncoder)
+		}
+	}
+
+	if entry.Stack != `` && e.StacktraceKey != `` {
+		appendLogfmt(final, e.StacktraceKey, entry.Stack)
+	}
+
+	if e.LineEnding != `` {
+		final.AppendString(e.LineEnding)
+	} else {
+		final.AppendString(zapcore.DefaultLineEnding)
+	}
+
+	return final, nil
+}
+
+// appendEncoded 使用配置中的Encode函数编码后追加
+func (e *logfmtEncoder) appendEncoded(buf *buffer.Buffer, key string, encode func(enc zapcore.PrimitiveArrayEncoder)) {
+	values := &logfmtArrayEncoder{config: e.EncoderConfig}
+	encode(values)
+
+	appendLogfmt(buf, key, strings.Join(values.values, ` `))
+}
+
+// appendLogfmt 追加一个key=value，按需加引号
+func appendLogfmt(buf *buffer.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.AppendByte(' ')
+	}
+
+	buf.AppendString(logfmtKey(key))
+	buf.AppendByte('=')
+	buf.AppendString(logfmtValue(value))
+}
+
+// logfmtKey 键名中的空格、=、"及控制字符替换为_
+func logfmtKey(key string) string {
+	if key == `` {
+		return `_`
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || unicode.IsControl(r) {
+			return '_'
+		}
+
+		return r
+	}, key)
+}
+
+// logfmtValue 值为空或者包含空格、=、"、控制字符及非法UTF-8时加引号并转义
+func logfmtValue(value string) string {
+	if value == `` {
+		return `""`
+	}
+
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || unicode.IsControl(r) || !unicode.IsPrint(r) {
+			return strconv.Quote(value)
+		}
+	}
+
+	return value
+}
+
+func (e *logfmtEncoder) add(key, value string) {
+	appendLogfmt(e.buf, e.prefix+key, value)
+}
+
+func (e *logfmtEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	values := &logfmtArrayEncoder{config: e.EncoderConfig}
+	err := marshaler.MarshalLogArray(values)
+	e.add(key, `[`+strings.Join(values.values, `,`)+`]`)
+
+	return err
+}
+
+func (e *logfmtEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	prefix := e.prefix
+	e.prefix = prefix + key + `.`
+	err := marshaler.MarshalLogObject(e)
+	e.prefix = prefix
+
+	return err
+}
+
+func (e *logfmtEncoder) AddBinary(key string, value []byte) {
+	e.add(key, base64.StdEncoding.EncodeToString(value))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, value []byte) {
+	e.add(key, string(value))
+}
+
+func (e *logfmtEncoder) AddBool(key string, value bool) {
+	e.add(key, strconv.FormatBool(value))
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, value complex128) {
+	e.add(key, strconv.FormatComplex(value, 'f', -1, 128))
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, value complex64) {
+	e.add(key, strconv.FormatComplex(complex128(value), 'f', -1, 64))
+}
+
+func (e *logfmtEncoder) AddDuration(key string, value time.Duration) {
+	if e.EncodeDuration == nil {
+		e.add(key, value.String())
+
+		return
+	}
+
+	e.appendEncoded(e.buf, e.prefix+key, func(enc zapcore.PrimitiveArrayEncoder) { e.EncodeDuration(value, enc) })
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, value float64) {
+	e.add(key, formatLogfmtFloat(value, 64))
+}
+
+func (e *logfmtEncoder) AddFloat32(key string, value float32) {
+	e.add(key, formatLogfmtFloat(float64(value), 32))
+}
+
+func (e *logfmtEncoder) AddInt(key string, value int) {
+	e.add(key, strconv.FormatInt(int64(value), 10))
+}
+
+func (e *logfmtEncoder) AddInt64(key string, value int64) {
+	e.add(key, strconv.FormatInt(value, 10))
+}
+
+func (e *logfmtEncoder) AddInt32(key string, value int32) {
+	e.add(key, strconv.FormatInt(int64(value), 10))
+}
+
+func (e *logfmtEncoder) AddInt16(key string, value int16) {
+	e.add(key, strconv.FormatInt(int64(value), 10))
+}
+
+func (e *logfmtEncoder) AddInt8(key string, value int8) {
+	e.add(key, strconv.FormatInt(int64(value), 10))
+}
+
+func (e *logfmtEncoder) AddString(key, value string) {
+	e.add(key, value)
+}
+
+func (e *logfmtEncoder) AddTime(key string, value time.Time) {
+	if e.EncodeTime == nil {
+		e.add(key, value.Format(time.RFC3339Nano))
+
+		return
+	}
+
+	e.appendEncoded(e.buf, e.prefix+key, func(enc zapcore.PrimitiveArrayEncoder) { e.EncodeTime(value, enc) })
+}
+
+func (e *logfmtEncoder) AddUint(key string, value uint) {
+	e.add(key, strconv.FormatUint(uint64(value), 10))
+}
+
+func (e *logfmtEncoder) AddUint64(key string, value uint64) {
+	e.add(key, strconv.FormatUint(value, 10))
+}
+
+func (e *logfmtEncoder) AddUint32(key string, value uint32) {
+	e.add(key, strconv.FormatUint(uint64(value), 10))
+}
+
+func (e *logfmtEncoder) AddUint16(key string, value uint16) {
+	e.add(key, strconv.FormatUint(uint64(value), 10))
+}
+
+func (e *logfmtEncoder) AddUint8(key string, value uint8) {
+	e.add(key, strconv.FormatUint(uint64(value), 10))
+}
+
+func (e *logfmtEncoder) AddUintptr(key string, value uintptr) {
+	e.add(key, strconv.FormatUint(uint64(value), 10))
+}
+
+// AddReflected map及结构体按JSON展开为.连接的键，其他值输出为JSON
+func (e *logfmtEncoder) AddReflected(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	// 保留数字原文，避免大整数丢失精度
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var generic interface{}
+	if err = decoder.Decode(&generic); err == nil {
+		if object, ok := generic.(map[string]interface{}); ok {
+			return e.AddObject(key, logfmtMap(object))
+		}
+	}
+
+	e.add(key, string(data))
+
+	return nil
+}
+
+func (e *logfmtEncoder) OpenNamespace(key string) {
+	e.prefix += key + `.`
+}
+
+// logfmtMap 将map作为对象展开
+type logfmtMap map[string]interface{}
+
+func (m logfmtMap) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch value := m[key].(type) {
+		case map[string]interface{}:
+			if err := enc.AddObject(key, logfmtMap(value)); err != nil {
+				return err
+			}
+		case string:
+			enc.AddString(key, value)
+		case nil:
+			enc.AddString(key, `null`)
+		default:
+			data, _ := json.Marshal(value)
+			enc.AddString(key, string(data))
+		}
+	}
+
+	return nil
+}
+
+func formatLogfmtFloat(value float64, bitSize int) string {
+	switch {
+	case math.IsNaN(value):
+		return `NaN`
+	case math.IsInf(value, 1):
+		return `+Inf`
+	case math.IsInf(value, -1):
+		return `-Inf`
+	default:
+		return strconv.FormatFloat(value, 'f', -1, bitSize)
+	}
+}
+
+// logfmtArrayEncoder 收集数组元素及Encode函数输出的文本
+type logfmtArrayEncoder struct {
+	config *zapcore.EncoderConfig
+	values []string
+}
+
+func (a *logfmtArrayEncoder) append(value string) {
+	a.values = append(a.values, value)
+}
+
+func (a *logfmtArrayEncoder) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	values := &logfmtArrayEncoder{config: a.config}
+	err := marshaler.MarshalLogArray(values)
+	a.append(`[` + strings.Join(values.values, `,`) + `]`)
+
+	return err
+}
+
+func (a *logfmtArrayEncoder) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	object := &logfmtEncoder{EncoderConfig: a.config, buf: logfmtPool.Get()}
+	defer object.buf.Free()
+
+	err := marshaler.MarshalLogObject(object)
+	a.append(`{` + object.buf.String() + `}`)
+
+	return err
+}
+
+func (a *logfmtArrayEncoder) AppendReflected(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	a.append(string(data))
+
+	return nil
+}
+
+func (a *logfmtArrayEncoder) AppendBool(value bool) {
+	a.append(strconv.FormatBool(value))
+}
+
+func (a *logfmtArrayEncoder) AppendByteString(value []byte) {
+	a.append(string(value))
+}
+
+func (a *logfmtArrayEncoder) AppendComplex128(value complex128) {
+	a.append(strconv.FormatComplex(value, 'f', -1, 128))
+}
+
+func (a *logfmtArrayEncoder) AppendComplex64(value complex64) {
+	a.append(strconv.FormatComplex(complex128(value), 'f', -1, 64))
+}
+
+func (a *logfmtArrayEncoder) AppendFloat64(value float64) {
+	a.append(formatLogfmtFloat(value, 64))
+}
+
+func (a *logfmtArrayEncoder) AppendFloat32(value float32) {
+	a.append(formatLogfmtFloat(float64(value), 32))
+}
+
+func (a *logfmtArrayEncoder) AppendInt(value int) {
+	a.append(strconv.FormatInt(int64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendInt64(value int64) {
+	a.append(strconv.FormatInt(value, 10))
+}
+
+func (a *logfmtArrayEncoder) AppendInt32(value int32) {
+	a.append(strconv.FormatInt(int64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendInt16(value int16) {
+	a.append(strconv.FormatInt(int64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendInt8(value int8) {
+	a.append(strconv.FormatInt(int64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendString(value string) {
+	a.append(value)
+}
+
+func (a *logfmtArrayEncoder) AppendUint(value uint) {
+	a.append(strconv.FormatUint(uint64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendUint64(value uint64) {
+	a.append(strconv.FormatUint(value, 10))
+}
+
+func (a *logfmtArrayEncoder) AppendUint32(value uint32) {
+	a.append(strconv.FormatUint(uint64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendUint16(value uint16) {
+	a.append(strconv.FormatUint(uint64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendUint8(value uint8) {
+	a.append(strconv.FormatUint(uint64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendUintptr(value uintptr) {
+	a.append(strconv.FormatUint(uint64(value), 10))
+}
+
+func (a *logfmtArrayEncoder) AppendDuration(value time.Duration) {
+	if a.config == nil || a.config.EncodeDuration == nil {
+		a.append(value.String())
+
+		return
+	}
+
+	a.config.EncodeDuration(value, a)
+}
+
+func (a *logfmtArrayEncoder) AppendTime(value time.Time) {
+	if a.config == nil || a.config.EncodeTime == nil {
+		a.append(value.Format(time.RFC3339Nano))
+
+		return
+	}
+
+	a.config.EncodeTime(value, a)
+}
@@ -0,0 +1,110 @@
+package log2
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type logfmtUser struct {
+	Name string
+	Age  int
+}
+
+func (u logfmtUser) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString(`name`, u.Name)
+	enc.AddInt(`age`, u.Age)
+
+	return nil
+}
+
+// TestLogfmtEncoder 测试引号转义、嵌套对象展开及数组
+func TestLogfmtEncoder(t *testing.T) {
+	encoder := NewLogfmtEncoder(zapcore.EncoderConfig{
+		TimeKey:        `ts`,
+		LevelKey:       `level`,
+		NameKey:        `logger`,
+		MessageKey:     `msg`,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.RFC3339TimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	})
+	encoder.AddString(`系统`, `test`)
+
+	entry := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		LoggerName: `sub`,
+		Message:    `hello world`,
+	}
+
+	buf, err := encoder.EncodeEntry(entry, []zapcore.Field{
+		zap.String(`plain`, `abc`),
+		zap.String(`space`, `a b`),
+		zap.String(`quote`, `say "hi"`),
+		zap.String(`equal`, `a=b`),
+		zap.String(`newline`, "a\nb"),
+		zap.String(`empty`, ``),
+		zap.String(`key with space`, `v`),
+		zap.Int(`count`, 3),
+		zap.Bool(`ok`, true),
+		zap.Duration(`elapsed`, 1500*time.Millisecond),
+		zap.Object(`user`, logfmtUser{Name: `张三`, Age: 18}),
+		zap.Strings(`tags`, []string{`a`, `b`}),
+		zap.Any(`extra`, map[string]interface{}{`inner`: map[string]interface{}{`x`: 1}, `y`: `z`}),
+		zap.Any(`obj`, map[string]int64{`id`: 1234567890123456789}),
+		zap.Error(errors.New(`boom`)),
+		zap.Namespace(`ns`),
+		zap.String(`k`, `v`),
+	})
+	require.NoError(t, err)
+
+	line := buf.String()
+	buf.Free()
+
+	require.Equal(t, `ts=2024-01-02T03:04:05Z level=warn logger=sub msg="hello world" 系统=test `+
+		`plain=abc space="a b" quote="say \"hi\"" equal="a=b" newline="a\nb" empty="" key_with_space=v `+
+		`count=3 ok=true elapsed=1.5s user.name=张三 user.age=18 tags=[a,b] extra.inner.x=1 extra.y=z `+
+		`obj.id=1234567890123456789 `+
+		`error=boom ns.k=v`+"\n", line)
+}
+
+// TestLogfmtEncoder_With With添加的字段及命名空间不影响原编码器
+func TestLogfmtEncoder_With(t *testing.T) {
+	encoder := NewLogfmtEncoder(zapcore.EncoderConfig{MessageKey: `msg`})
+	clone := encoder.Clone()
+	clone.OpenNamespace(`req`)
+	clone.AddString(`id`, `1`)
+
+	buf, err := clone.EncodeEntry(zapcore.Entry{Message: `a`}, []zapcore.Field{zap.Int(`n`, 1)})
+	require.NoError(t, err)
+	require.Equal(t, "msg=a req.id=1 req.n=1\n", buf.String())
+
+	buf, err = encoder.EncodeEntry(zapcore.Entry{Message: `b`}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "msg=b\n", buf.String())
+}
+
+// TestConfig_Format 测试通过Format选择logfmt输出
+func TestConfig_Format(t *testing.T) {
+	hook := &bufferHook{minLevel: zapcore.DebugLevel}
+	root, err := (&Config{Service: "test", HideConsole: true, Format: FormatLogfmt, TimeZone: `UTC`, Hooks: []Hook{hook}}).Build()
+	require.NoError(t, err)
+
+	root.Derive(`sub`).Info(`你好 世界`, zap.Int(`n`, 1))
+
+	line := hook.String()
+	require.True(t, strings.HasPrefix(line, `T=`), line)
+	require.Contains(t, line, ` L=INFO N=sub C=`)
+	require.Contains(t, line, `logfmt_test.go:`)
+	require.Contains(t, line, ` M="你好 世界" 系统=test n=1`)
+	require.NotContains(t, line, "\x1b", `logfmt不输出颜色`)
+
+	_, err = (&Config{Format: `xml`}).Build()
+	require.Error(t, err)
+}
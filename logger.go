@@ -1,12 +1,11 @@
 package log2
 
 import (
+	"context"
 	"io"
 	"log"
-	"os"
 	"strings"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -45,12 +44,30 @@ type Logger interface {
 	Fatal(msg string, fields ...zap.Field)
 	// Panic 输出日志到Panic 级别
 	Panic(msg string, fields ...zap.Field)
-	// Start 返回一个携带任务ID字段的日志器
+	// Start 返回一个携带新生成的任务ID字段的日志器
 	Start() Logger
-	// SetLevel 设置级别，可以调高或者调低
+	// StartWithID 返回一个携带指定任务ID字段的日志器，用于沿用上游传入的ID，id为空时同Start
+	StartWithID(id string) Logger
+	// SetLevel 设置级别，可以调高或者调低，只影响当前名称及未单独设置级别的衍生日志器
 	SetLevel(level zapcore.Level) Logger
+	// Level 获取当前生效的级别
+	Level() zapcore.Level
 	// AddCallerSkip
 	AddCallerSkip(skip int) Logger
+	// WithContext 返回携带context中日志器及字段的日志器
+	WithContext(ctx context.Context) Logger
+	// DebugContext 携带context中的字段输出日志到Debug 级别
+	DebugContext(ctx context.Context, msg string, fields ...zap.Field)
+	// InfoContext 携带context中的字段输出日志到Info 级别
+	InfoContext(ctx context.Context, msg string, fields ...zap.Field)
+	// WarnContext 携带context中的字段输出日志到Warn 级别
+	WarnContext(ctx context.Context, msg string, fields ...zap.Field)
+	// ErrorContext 携带context中的字段输出日志到Error 级别
+	ErrorContext(ctx context.Context, msg string, fields ...zap.Field)
+	// Sync 将所有输出中缓冲的日志写入
+	Sync() error
+	// Close 同步后关闭Config.Build打开的文件等资源，同一棵日志器树共享，关闭一次即可
+	Close() error
 }
 
 func ensureDuplicateKeys(data *Exist) *Exist {
@@ -63,11 +80,13 @@ func ensureDuplicateKeys(data *Exist) *Exist {
 // logger 日志器的实现
 type logger struct {
 	underlying    *zap.Logger
+	instance      *instance // 同一棵日志器树共享的实例
 	levelToPath   map[zapcore.Level]string
 	duplicateKeys *Exist
 	name          string
 	fields        []zapcore.Field
-	skip          int
+	withFields    []zapcore.Field // With添加的字段，放入context后由其他日志器的WithContext合并
+	skip          int             // 相对底层日志器累计跳过的堆栈
 }
 
 /*
@@ -130,17 +149,27 @@ func (l *logger) Derive(s string) Logger {
 		names = append(names, l.name, s)
 	}
 
-	return NewLogger(l.underlying, strings.Join(names, "."), -1, true, true, l.levelToPath, l.duplicateKeys.Copy(), l.fields...)
+	result := l.inherit(NewLogger(l.underlying, strings.Join(names, "."), -1, true, true, l.levelToPath, l.duplicateKeys.Copy(), l.fields...))
+	if result.instance != nil {
+		result.instance.levels.register(result.name)
+	}
+
+	return result
 }
 
 func (l logger) With(fields ...zap.Field) Logger {
+	return l.with(fields...)
+}
+
+func (l logger) with(fields ...zap.Field) *logger {
 	if l.underlying == nil {
 		return &l
 	}
 
-	fields = append(l.fields, fields...)
+	result := l.inherit(NewLogger(l.underlying.With(append(l.fields, fields...)...), l.name, -1, false, false, l.levelToPath, l.duplicateKeys.Copy()))
+	result.withFields = append(l.withFields[:len(l.withFields):len(l.withFields)], fields...)
 
-	return NewLogger(l.underlying.With(fields...), l.name, -1, false, false, l.levelToPath, l.duplicateKeys.Copy())
+	return result
 }
 
 func (l logger) WithWhenNotExist(key string, field zap.Field) Logger {
@@ -163,7 +192,10 @@ func (l logger) WithWhenNotExist(key string, field zap.Field) Logger {
 
 	duplicate.Set(key)
 
-	return NewLogger(l.underlying.With(fields...), l.name, -1, false, false, l.levelToPath, duplicate)
+	result := l.inherit(NewLogger(l.underlying.With(fields...), l.name, -1, false, false, l.levelToPath, duplicate))
+	result.withFields = append(l.withFields[:len(l.withFields):len(l.withFields)], field)
+
+	return result
 }
 
 func (l logger) Info(msg string, fields ...zap.Field) {
@@ -202,42 +234,222 @@ func (l logger) Panic(msg string, fields ...zap.Field) {
 	}
 }
 
-func (l logger) SetLevel(level zapcore.Level) Logger {
+/*
+SetLevel 设置当前名称的级别，立即生效且保留所有输出
+未通过Config.Build构建的日志器会在底层core外包装一层级别控制
+参数:
+*	level 	zapcore.Level	级别
+返回值:
+*	Logger	Logger       	日志器
+*/
+func (l *logger) SetLevel(level zapcore.Level) Logger {
 	debugPrintln(`setLevel`, level, l.name)
 
-	var allCore []zapcore.Core
-	if writeSyncer != nil {
-		allCore = append(allCore, zapcore.NewCore(
-			encoder,
-			writeSyncer,
-			level,
-		))
-	}
+	if l.instance != nil {
+		l.instance.levels.SetLevel(l.name, level)
 
-	for _, inputCore := range inputCores {
-		if inputCore != nil {
-			allCore = append(allCore, inputCore)
-		}
+		return l
 	}
 
-	if !HiddenConsole {
-		allCore = append(allCore, zapcore.NewCore(encoder, os.Stdout, level))
+	if l.underlying == nil {
+		return l
 	}
 
-	core = zapcore.NewTee(allCore...)
-
-	resultLogger := zap.New(core).With(l.fields...)
-	resultLogger = resultLogger.WithOptions(zap.AddCaller())
+	levels := newLevelRegistry(level)
+	underlying := l.underlying.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return newLevelCore(core, levels)
+	}))
 
-	result := NewLogger(resultLogger, l.name, 1, true, false, l.levelToPath, nil, l.fields...)
+	result := l.inherit(NewLogger(underlying, l.name, -1, false, false, l.levelToPath, l.duplicateKeys.Copy(), l.fields...))
+	result.instance = &instance{levels: levels}
+	result.instance.output.Store(&output{core: underlying.Core()})
 
 	return result
 }
 
+/*
+Level 获取当前生效的级别
+返回值:
+*	zapcore.Level	zapcore.Level	级别，未设置过级别控制时为底层core允许的最低级别
+*/
+func (l *logger) Level() zapcore.Level {
+	if l.instance != nil {
+		return l.instance.levels.Level(l.name)
+	}
+
+	if l.underlying == nil {
+		return zapcore.InvalidLevel
+	}
+
+	return l.underlying.Level()
+}
+
+/*
+Start 返回一个携带新生成的任务ID字段的日志器，生成方式及字段名由Config.TaskID决定
+返回值:
+*	Logger	Logger	日志器
+*/
 func (l *logger) Start() Logger {
-	return l.With(zap.String(`任务ID`, primitive.NewObjectID().Hex()))
+	config := l.taskIDConfig()
+
+	return l.With(zap.String(config.Key, config.newID()))
+}
+
+/*
+StartWithID 返回一个携带指定任务ID字段的日志器
+参数:
+*	id    	string	任务ID，为空时生成新的ID
+返回值:
+*	Logger	Logger	日志器
+*/
+func (l *logger) StartWithID(id string) Logger {
+	if id == `` {
+		return l.Start()
+	}
+
+	return l.With(zap.String(l.taskIDConfig().Key, id))
+}
+
+func (l *logger) taskIDConfig() *TaskIDConfig {
+	if l.instance != nil {
+		if out := l.instance.output.Load(); out != nil && out.taskID != nil {
+			return out.taskID
+		}
+	}
+
+	return defaultTaskID
 }
 
 func (l *logger) AddCallerSkip(skip int) Logger {
-	return NewLogger(l.underlying, l.name, skip, false, false, l.levelToPath, l.duplicateKeys)
+	if l.underlying == nil {
+		return l
+	}
+
+	result := l.inherit(NewLogger(l.underlying, l.name, skip, false, false, l.levelToPath, l.duplicateKeys))
+	result.skip = l.skip + skip
+
+	return result
+}
+
+/*
+WithContext 返回携带context信息的日志器
+context中通过IntoContext放入了日志器时合并该日志器通过With添加的字段(任务ID等)，名称、级别及输出仍使用当前日志器，
+再追加通过ContextWithFields放入的字段，配置了Config.Trace时追加context中span的字段
+参数:
+*	ctx   	context.Context	上下文
+返回值:
+*	Logger	Logger         	日志器
+*/
+func (l *logger) WithContext(ctx context.Context) Logger {
+	return l.withContext(ctx)
+}
+
+func (l *logger) withContext(ctx context.Context) *logger {
+	if ctx == nil {
+		return l
+	}
+
+	result := l
+
+	if ctxLogger, ok := ctx.Value(LoggerKey).(*logger); ok && ctxLogger != nil && ctxLogger != l {
+		result = result.mergeFields(ctxLogger.withFields)
+	}
+
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		result = result.with(fields...)
+	}
+
+	return result.withTrace(ctx)
+}
+
+func (l logger) DebugContext(ctx context.Context, msg string, fields ...zap.Field) {
+	if target := l.withContext(ctx); target.underlying != nil {
+		target.underlying.Debug(msg, fields...)
+	}
+}
+
+func (l logger) InfoContext(ctx context.Context, msg string, fields ...zap.Field) {
+	if target := l.withContext(ctx); target.underlying != nil {
+		target.underlying.Info(msg, fields...)
+	}
+}
+
+func (l logger) WarnContext(ctx context.Context, msg string, fields ...zap.Field) {
+	if target := l.withContext(ctx); target.underlying != nil {
+		target.underlying.Warn(msg, fields...)
+	}
+}
+
+func (l logger) ErrorContext(ctx context.Context, msg string, fields ...zap.Field) {
+	if target := l.withContext(ctx); target.underlying != nil {
+		target.underlying.Error(msg, fields...)
+	}
+}
+
+/*
+Sync 将所有输出(文件、按级别的文件、Hooks、额外的core)中缓冲的日志写入
+返回值:
+*	error	error	错误
+*/
+func (l *logger) Sync() error {
+	if l.instance != nil {
+		return l.instance.sync()
+	}
+
+	if l.underlying == nil {
+		return nil
+	}
+
+	return l.underlying.Sync()
+}
+
+/*
+Close 同步并关闭Config.Build打开的文件等资源
+关闭后仍可以继续输出日志，文件会重新打开，一般在进程退出前调用
+返回值:
+*	error	error	错误
+*/
+func (l *logger) Close() error {
+	if l.instance != nil {
+		return l.instance.close()
+	}
+
+	return l.Sync()
+}
+
+// mergeFields 追加当前日志器还没有的字段，按key判断
+func (l *logger) mergeFields(fields []zapcore.Field) *logger {
+	var missing []zapcore.Field
+
+	for _, field := range fields {
+		if !l.hasField(field.Key) {
+			missing = append(missing, field)
+		}
+	}
+
+	if len(missing) == 0 {
+		return l
+	}
+
+	return l.with(missing...)
+}
+
+// hasField 是否已经通过With添加了key对应的字段
+func (l *logger) hasField(key string) bool {
+	for _, field := range l.withFields {
+		if field.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inherit 将当前日志器的运行时信息带到衍生出的日志器上
+func (l *logger) inherit(result *logger) *logger {
+	result.skip = l.skip
+	result.instance = l.instance
+	result.withFields = l.withFields
+
+	return result
 }
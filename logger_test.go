@@ -232,31 +232,6 @@ func TestLogger_FatalAndPanic(t *testing.T) {
 // TestLogger_SetLevelMethod 测试SetLevel方法
 func TestLogger_SetLevelFunc(t *testing.T) {
 	t.Run("基本SetLevel测试", func(t *testing.T) {
-		// 保存原始状态
-		originalHiddenConsole := HiddenConsole
-		originalWriteSyncer := writeSyncer
-		originalInputCores := inputCores
-		originalEncoder := encoder
-		originalCore := core
-		
-		// 设置测试环境
-		HiddenConsole = true
-		writeSyncer = nil
-		inputCores = nil
-		encoder = zapcore.NewJSONEncoder(zapcore.EncoderConfig{
-			MessageKey: "message",
-			LevelKey:   "level",
-		})
-		
-		// 恢复原始状态
-		defer func() {
-			HiddenConsole = originalHiddenConsole
-			writeSyncer = originalWriteSyncer
-			inputCores = originalInputCores
-			encoder = originalEncoder
-			core = originalCore
-		}()
-		
 		core, _ := observer.New(zapcore.InfoLevel)
 		zapLogger := zap.New(core)
 		
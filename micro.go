@@ -39,19 +39,25 @@ func (m microLogger) Fields(fields map[string]interface{}) microlog.Logger {
 }
 
 func (m microLogger) Log(level microlog.Level, v ...interface{}) {
+	// zh方案沿用原来的空字段名，选择其他方案或覆盖字段名时使用配置的名称
+	key := fieldNamesOf(m.Logger)[FieldArgs]
+	if key == zhFieldNames[FieldArgs] {
+		key = ``
+	}
+
 	switch level {
 	case microlog.InfoLevel:
-		m.Logger.Info(``, zap.Any(``, v))
+		m.Logger.Info(``, zap.Any(key, v))
 	case microlog.DebugLevel, microlog.TraceLevel:
-		m.Logger.Debug(``, zap.Any(``, v))
+		m.Logger.Debug(``, zap.Any(key, v))
 	case microlog.WarnLevel:
-		m.Logger.Warn(``, zap.Any(``, v))
+		m.Logger.Warn(``, zap.Any(key, v))
 	case microlog.ErrorLevel:
-		m.Logger.Error(``, zap.Any(``, v))
+		m.Logger.Error(``, zap.Any(key, v))
 	case microlog.FatalLevel:
-		m.Logger.Fatal(``, zap.Any(``, v))
+		m.Logger.Fatal(``, zap.Any(key, v))
 	default:
-		m.Logger.Info(``, zap.Any(``, v))
+		m.Logger.Info(``, zap.Any(key, v))
 	}
 }
 
@@ -63,19 +63,22 @@ func anyToZapFieldMongo(data ...any) []zap.Field {
 func (l MongoLogger) CommandMonitor() *event.CommandMonitor {
 	return &event.CommandMonitor{
 		Started: func(ctx context.Context, startedEvent *event.CommandStartedEvent) {
-			l.Logger.Info(`开始执行`, zap.Int64(`请求ID`, startedEvent.RequestID), zap.Any(`command`, startedEvent.Command.String()))
+			names := fieldNamesOf(l.Logger)
+			l.Logger.WithContext(ctx).Info(`开始执行`, zap.Int64(names[FieldRequestID], startedEvent.RequestID), zap.Any(names[FieldCommand], startedEvent.Command.String()))
 		},
 		Succeeded: func(ctx context.Context, succeededEvent *event.CommandSucceededEvent) {
 			var (
 				id       = succeededEvent.RequestID
 				duration = succeededEvent.Duration
 				result   = succeededEvent.Reply.String()
+				names    = fieldNamesOf(l.Logger)
 			)
-			l.Logger.Info(`执行成功`, zap.Int64(`请求ID`, id), zap.Duration(`耗时`, duration), zap.Any(`result`, result))
+			l.Logger.WithContext(ctx).Info(`执行成功`, zap.Int64(names[FieldRequestID], id), zap.Duration(names[FieldElapsed], duration), zap.Any(names[FieldResult], result))
 		},
 		Failed: func(ctx context.Context, failedEvent *event.CommandFailedEvent) {
 			id := failedEvent.RequestID
-			l.Logger.Info(`执行失败`, zap.Int64(`请求ID`, id), zap.Duration(`耗时`, failedEvent.Duration), zap.String(`原因`, failedEvent.Failure))
+			names := fieldNamesOf(l.Logger)
+			l.Logger.WithContext(ctx).Info(`执行失败`, zap.Int64(names[FieldRequestID], id), zap.Duration(names[FieldElapsed], failedEvent.Duration), zap.String(names[FieldReason], failedEvent.Failure))
 		},
 	}
 }
@@ -33,7 +33,7 @@ func TestMongo(t *testing.T) {
 	// Make a database request to test our logging solution.
 	coll := client.Database("test").Collection("test")
 
-	_, err = coll.InsertOne(context.TODO(), bson.D{{"Alice", "123"}})
+	_, err = coll.InsertOne(context.TODO(), bson.D{{Key: "Alice", Value: "123"}})
 	require.NoError(t, err)
 }
 
@@ -59,6 +59,6 @@ func TestMongoWithMonitor(t *testing.T) {
 	// Make a database request to test our logging solution.
 	coll := client.Database("test").Collection("test")
 
-	_, err = coll.InsertOne(context.TODO(), bson.D{{"Alice", "123"}})
+	_, err = coll.InsertOne(context.TODO(), bson.D{{Key: "Alice", Value: "123"}})
 	require.NoError(t, err)
 }
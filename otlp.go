@@ -0,0 +1,644 @@
+package log2
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLPProtocol OTLP传输协议
+type OTLPProtocol string
+
+const (
+	// OTLPHTTP OTLP/HTTP，使用protobuf编码
+	OTLPHTTP = OTLPProtocol(`http`)
+	// OTLPGRPC OTLP/gRPC
+	OTLPGRPC = OTLPProtocol(`grpc`)
+)
+
+const (
+	defaultOTLPBatchSize     = 512
+	defaultOTLPQueueSize     = 4096
+	defaultOTLPFlushInterval = 1000
+	defaultOTLPTimeout       = 10000
+	defaultOTLPMaxRetries    = 3
+	defaultOTLPRetryInterval = 500
+	otlpHTTPPath             = `/v1/logs`
+	otlpScopeName            = `github.com/go-utils2/log2`
+)
+
+// OTLPConfig OTLP导出配置
+type OTLPConfig struct {
+	Endpoint      string            `yaml:"endpoint"`      // 地址，http为http://host:4318(未指定路径时使用/v1/logs)，grpc为host:4317
+	Protocol      OTLPProtocol      `yaml:"protocol"`      // 协议，http/grpc，默认http
+	Insecure      bool              `yaml:"insecure"`      // grpc是否不使用TLS
+	Headers       map[string]string `yaml:"headers"`       // 请求头或者gRPC元数据
+	Resource      map[string]string `yaml:"resource"`      // 额外的资源属性，service.name默认为Config.Service
+	BatchSize     int               `yaml:"batchSize"`     // 每批最多的日志条数，默认512
+	QueueSize     int               `yaml:"queueSize"`     // 等待导出的日志条数，队列满时丢弃，默认4096
+	FlushInterval int               `yaml:"flushInterval"` // 导出间隔,单位为毫秒，默认1000
+	Timeout       int               `yaml:"timeout"`       // 单次导出超时,单位为毫秒，默认10000
+	MaxRetries    int               `yaml:"maxRetries"`    // 可重试错误的最大重试次数，默认3，小于0时不重试
+	RetryInterval int               `yaml:"retryInterval"` // 首次重试间隔,单位为毫秒，之后每次翻倍，默认500
+}
+
+func (c *OTLPConfig) tidy() error {
+	if c.Endpoint == `` {
+		return errors.New(`OTLP地址为空`)
+	}
+
+	switch c.Protocol {
+	case ``:
+		c.Protocol = OTLPHTTP
+	case OTLPHTTP, OTLPGRPC:
+	default:
+		return errors.Errorf(`未知的OTLP协议[%s]`, c.Protocol)
+	}
+
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultOTLPBatchSize
+	}
+
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultOTLPQueueSize
+	}
+
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaultOTLPFlushInterval
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = defaultOTLPTimeout
+	}
+
+	if c.MaxRetries == 0 {
+		c.MaxRetries = defaultOTLPMaxRetries
+	}
+
+	if c.RetryInterval <= 0 {
+		c.RetryInterval = defaultOTLPRetryInterval
+	}
+
+	return nil
+}
+
+// OTLPStats OTLP导出统计
+type OTLPStats struct {
+	Exported uint64 // 导出成功的条数
+	Dropped  uint64 // 因队列满丢弃的条数
+	Failed   uint64 // 重试后依然导出失败的条数
+}
+
+// otlpExporter 导出一批日志
+type otlpExporter interface {
+	export(ctx context.Context, request *collectorlogs.ExportLogsServiceRequest) error
+	close() error
+}
+
+// otlpItem 队列中的一条日志，flushed不为空时表示刷新请求
+type otlpItem struct {
+	record  *logspb.LogRecord
+	flushed chan struct{}
+}
+
+// OTLPCore 将日志转换为OTLP LogRecord并批量导出的core
+type OTLPCore struct {
+	config   *OTLPConfig
+	resource *resourcepb.Resource
+	exporter otlpExporter
+	traceKey string // 转为记录链路信息的链路ID字段名
+	spanKey  string // 转为记录链路信息的span ID字段名
+	queue    chan otlpItem
+	batch    []*logspb.LogRecord
+	lock     sync.RWMutex // 保护closed
+	closed   bool
+	stopped  chan struct{}
+	exported atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+	errLock  sync.Mutex
+	err      error // 后台导出的错误，Sync时返回
+}
+
+/*
+NewOTLPCore 生成导出到OTLP的core，可以传给Config.Build，也可以通过Config.OTLP配置
+级别由日志器控制，core本身接受所有级别，使用完毕后需要Close
+参数:
+*	config   	*OTLPConfig	配置
+*	service  	string     	服务名称，作为资源属性service.name
+返回值:
+*	*OTLPCore	*OTLPCore  	core
+*	error    	error      	错误
+*/
+func NewOTLPCore(config *OTLPConfig, service string) (*OTLPCore, error) {
+	if err := config.tidy(); err != nil {
+		return nil, errors.Wrap(err, `OTLP配置`)
+	}
+
+	var (
+		exporter otlpExporter
+		err      error
+	)
+
+	if config.Protocol == OTLPGRPC {
+		exporter, err = newOTLPGRPCExporter(config)
+	} else {
+		exporter, err = newOTLPHTTPExporter(config)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newOTLPCore(config, service, exporter), nil
+}
+
+func newOTLPCore(config *OTLPConfig, service string, exporter otlpExporter) *OTLPCore {
+	attributes := map[string]string{`service.name`: service}
+	for key, value := range config.Resource {
+		attributes[key] = value
+	}
+
+	result := &OTLPCore{
+		config:   config,
+		resource: &resourcepb.Resource{Attributes: otlpAttributes(attributes)},
+		exporter: exporter,
+		traceKey: zhFieldNames[FieldTraceID],
+		spanKey:  zhFieldNames[FieldSpanID],
+		queue:    make(chan otlpItem, config.QueueSize),
+		stopped:  make(chan struct{}),
+	}
+
+	go result.run()
+
+	return result
+}
+
+// Stats 获取导出统计
+func (c *OTLPCore) Stats() OTLPStats {
+	return OTLPStats{Exported: c.exported.Load(), Dropped: c.dropped.Load(), Failed: c.failed.Load()}
+}
+
+func (c *OTLPCore) run() {
+	defer close(c.stopped)
+
+	ticker := time.NewTicker(time.Duration(c.config.FlushInterval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case item, ok := <-c.queue:
+			if !ok {
+				c.flush()
+
+				return
+			}
+
+			if item.flushed != nil {
+				c.flush()
+				close(item.flushed)
+
+				continue
+			}
+
+			c.batch = append(c.batch, item.record)
+			if len(c.batch) >= c.config.BatchSize {
+				c.flush()
+			}
+		case <-ticker.C:
+			c.flush()
+		}
+	}
+}
+
+// flush 导出当前批次，可重试的错误按间隔翻倍重试
+func (c *OTLPCore) flush() {
+	if len(c.batch) == 0 {
+		return
+	}
+
+	request := &collectorlogs.ExportLogsServiceRequest{ResourceLogs: []*logspb.ResourceLogs{{
+		Resource: c.resource,
+		ScopeLogs: []*logspb.ScopeLogs{{
+			Scope:      &commonpb.InstrumentationScope{Name: otlpScopeName},
+			LogRecords: c.batch,
+		}},
+	}}}
+
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.config.Timeout)*time.Millisecond)
+		err = c.exporter.export(ctx, request)
+		cancel()
+
+		if err == nil || attempt >= c.config.MaxRetries || !otlpRetryable(err) {
+			break
+		}
+
+		time.Sleep(time.Duration(float64(c.config.RetryInterval)*math.Pow(2, float64(attempt))) * time.Millisecond)
+	}
+
+	if err != nil {
+		c.failed.Add(uint64(len(c.batch)))
+		c.setErr(errors.Wrapf(err, `导出%d条日志`, len(c.batch)))
+	} else {
+		c.exported.Add(uint64(len(c.batch)))
+	}
+
+	c.batch = nil
+}
+
+func (c *OTLPCore) setErr(err error) {
+	c.errLock.Lock()
+	defer c.errLock.Unlock()
+
+	c.err = err
+}
+
+func (c *OTLPCore) takeErr() error {
+	c.errLock.Lock()
+	defer c.errLock.Unlock()
+
+	err := c.err
+	c.err = nil
+
+	return err
+}
+
+func (c *OTLPCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *OTLPCore) With(fields []zapcore.Field) zapcore.Core {
+	return &otlpFieldsCore{OTLPCore: c, fields: fields}
+}
+
+func (c *OTLPCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return checked.AddCore(entry, c)
+}
+
+func (c *OTLPCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.write(entry, nil, fields)
+}
+
+func (c *OTLPCore) write(entry zapcore.Entry, contextFields, fields []zapcore.Field) error {
+	record := otlpRecord(entry, contextFields, fields, c.traceKey, c.spanKey)
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.closed {
+		return errors.New(`OTLP导出已关闭`)
+	}
+
+	select {
+	case c.queue <- otlpItem{record: record}:
+	default:
+		c.dropped.Add(1)
+	}
+
+	return nil
+}
+
+// Sync 等待此前写入的日志全部导出，返回期间导出的错误
+func (c *OTLPCore) Sync() error {
+	c.lock.RLock()
+
+	if c.closed {
+		c.lock.RUnlock()
+
+		return nil
+	}
+
+	flushed := make(chan struct{})
+	c.queue <- otlpItem{flushed: flushed}
+	c.lock.RUnlock()
+
+	<-flushed
+
+	return c.takeErr()
+}
+
+// Close 导出队列中的日志后停止后台协程并关闭连接
+func (c *OTLPCore) Close() error {
+	c.lock.Lock()
+
+	if c.closed {
+		c.lock.Unlock()
+
+		return nil
+	}
+
+	c.closed = true
+	close(c.queue)
+	c.lock.Unlock()
+
+	<-c.stopped
+
+	return errors.Wrap(multierr.Append(c.takeErr(), c.exporter.close()), `关闭OTLP导出`)
+}
+
+// otlpFieldsCore 携带With字段的OTLPCore
+type otlpFieldsCore struct {
+	*OTLPCore
+	fields []zapcore.Field
+}
+
+func (c *otlpFieldsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &otlpFieldsCore{OTLPCore: c.OTLPCore, fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *otlpFieldsCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return checked.AddCore(entry, c)
+}
+
+func (c *otlpFieldsCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.write(entry, c.fields, fields)
+}
+
+// otlpSeverity zap级别对应的OTLP严重程度
+func otlpSeverity(level zapcore.Level) logspb.SeverityNumber {
+	switch level {
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case zapcore.DPanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR2
+	case zapcore.PanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case zapcore.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL2
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+	}
+}
+
+// otlpRecord 将日志转换为LogRecord，traceKey及spanKey字段转为记录的链路信息
+func otlpRecord(entry zapcore.Entry, contextFields, fields []zapcore.Field, traceKey, spanKey string) *logspb.LogRecord {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range contextFields {
+		field.AddTo(encoder)
+	}
+
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(entry.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       otlpSeverity(entry.Level),
+		SeverityText:         entry.Level.CapitalString(),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: entry.Message}},
+	}
+
+	if traceID, ok := encoder.Fields[traceKey].(string); ok {
+		if data, err := hex.DecodeString(traceID); err == nil && len(data) == 16 {
+			record.TraceId = data
+			delete(encoder.Fields, traceKey)
+		}
+	}
+
+	if spanID, ok := encoder.Fields[spanKey].(string); ok {
+		if data, err := hex.DecodeString(spanID); err == nil && len(data) == 8 {
+			record.SpanId = data
+			delete(encoder.Fields, spanKey)
+		}
+	}
+
+	if entry.LoggerName != `` {
+		encoder.Fields[`logger.name`] = entry.LoggerName
+	}
+
+	if entry.Caller.Defined {
+		encoder.Fields[`code.filepath`] = entry.Caller.File
+		encoder.Fields[`code.lineno`] = int64(entry.Caller.Line)
+		encoder.Fields[`code.function`] = entry.Caller.Function
+	}
+
+	record.Attributes = otlpKeyValues(encoder.Fields)
+
+	return record
+}
+
+func otlpAttributes(values map[string]string) []*commonpb.KeyValue {
+	generic := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		generic[key] = value
+	}
+
+	return otlpKeyValues(generic)
+}
+
+func otlpKeyValues(values map[string]interface{}) []*commonpb.KeyValue {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	result := make([]*commonpb.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, &commonpb.KeyValue{Key: key, Value: otlpValue(values[key])})
+	}
+
+	return result
+}
+
+// otlpValue 将MapObjectEncoder编码的值转换为AnyValue
+func otlpValue(value interface{}) *commonpb.AnyValue {
+	switch typed := value.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: typed}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: typed}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: typed}}
+	case int32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(typed)}}
+	case uint64:
+		if typed > math.MaxInt64 {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(typed)}}
+		}
+
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(typed)}}
+	case uint32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(typed)}}
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(typed)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: typed}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: typed}}
+	case time.Time:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: typed.Format(time.RFC3339Nano)}}
+	case time.Duration:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: typed.String()}}
+	case map[string]interface{}:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: otlpKeyValues(typed)}}}
+	case []interface{}:
+		items := make([]*commonpb.AnyValue, 0, len(typed))
+		for _, item := range typed {
+			items = append(items, otlpValue(item))
+		}
+
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: items}}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(typed)}}
+	}
+}
+
+// otlpError 导出错误，retryable表示是否可以重试
+type otlpError struct {
+	err       error
+	retryable bool
+}
+
+func (e *otlpError) Error() string {
+	return e.err.Error()
+}
+
+func otlpRetryable(err error) bool {
+	var target *otlpError
+	if errors.As(err, &target) {
+		return target.retryable
+	}
+
+	return false
+}
+
+// otlpHTTPExporter 通过OTLP/HTTP导出
+type otlpHTTPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func newOTLPHTTPExporter(config *OTLPConfig) (*otlpHTTPExporter, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, `解析OTLP地址[%s]`, config.Endpoint)
+	}
+
+	if endpoint.Path == `` || endpoint.Path == `/` {
+		endpoint.Path = otlpHTTPPath
+	}
+
+	return &otlpHTTPExporter{endpoint: endpoint.String(), headers: config.Headers, client: &http.Client{}}, nil
+}
+
+func (e *otlpHTTPExporter) export(ctx context.Context, request *collectorlogs.ExportLogsServiceRequest) error {
+	data, err := proto.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, `编码`)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, `生成请求`)
+	}
+
+	httpRequest.Header.Set(`Content-Type`, `application/x-protobuf`)
+
+	for key, value := range e.headers {
+		httpRequest.Header.Set(key, value)
+	}
+
+	response, err := e.client.Do(httpRequest)
+	if err != nil {
+		return &otlpError{err: errors.Wrap(err, `发送请求`), retryable: true}
+	}
+
+	defer response.Body.Close()
+
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	switch {
+	case response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices:
+		return nil
+	case response.StatusCode == http.StatusTooManyRequests, response.StatusCode == http.StatusBadGateway,
+		response.StatusCode == http.StatusServiceUnavailable, response.StatusCode == http.StatusGatewayTimeout:
+		return &otlpError{err: errors.Errorf(`状态码%d`, response.StatusCode), retryable: true}
+	default:
+		return &otlpError{err: errors.Errorf(`状态码%d`, response.StatusCode)}
+	}
+}
+
+func (e *otlpHTTPExporter) close() error {
+	e.client.CloseIdleConnections()
+
+	return nil
+}
+
+// otlpGRPCExporter 通过OTLP/gRPC导出
+type otlpGRPCExporter struct {
+	conn    *grpc.ClientConn
+	client  collectorlogs.LogsServiceClient
+	headers metadata.MD
+}
+
+func newOTLPGRPCExporter(config *OTLPConfig, opts ...grpc.DialOption) (*otlpGRPCExporter, error) {
+	if config.Insecure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})))
+	}
+
+	conn, err := grpc.NewClient(config.Endpoint, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, `连接OTLP地址[%s]`, config.Endpoint)
+	}
+
+	return &otlpGRPCExporter{conn: conn, client: collectorlogs.NewLogsServiceClient(conn), headers: metadata.New(config.Headers)}, nil
+}
+
+func (e *otlpGRPCExporter) export(ctx context.Context, request *collectorlogs.ExportLogsServiceRequest) error {
+	_, err := e.client.Export(metadata.NewOutgoingContext(ctx, e.headers), request)
+	if err == nil {
+		return nil
+	}
+
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.OutOfRange,
+		codes.Unavailable, codes.DataLoss:
+		return &otlpError{err: err, retryable: true}
+	default:
+		return &otlpError{err: err}
+	}
+}
+
+func (e *otlpGRPCExporter) close() error {
+	return e.conn.Close()
+}
@@ -0,0 +1,202 @@
+package log2
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCollector 记录收到的导出请求
+type fakeCollector struct {
+	collectorlogs.UnimplementedLogsServiceServer
+	lock     sync.Mutex
+	requests []*collectorlogs.ExportLogsServiceRequest
+	headers  []string
+}
+
+func (c *fakeCollector) Export(ctx context.Context, request *collectorlogs.ExportLogsServiceRequest) (*collectorlogs.ExportLogsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.add(request, md.Get(`x-token`)...)
+
+	return &collectorlogs.ExportLogsServiceResponse{}, nil
+}
+
+func (c *fakeCollector) add(request *collectorlogs.ExportLogsServiceRequest, headers ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.requests = append(c.requests, request)
+	c.headers = append(c.headers, headers...)
+}
+
+// records 收到的所有日志
+func (c *fakeCollector) records() (resource map[string]string, records []*logspb.LogRecord) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	resource = map[string]string{}
+
+	for _, request := range c.requests {
+		for _, resourceLogs := range request.ResourceLogs {
+			for _, attribute := range resourceLogs.Resource.Attributes {
+				resource[attribute.Key] = attribute.Value.GetStringValue()
+			}
+
+			for _, scopeLogs := range resourceLogs.ScopeLogs {
+				records = append(records, scopeLogs.LogRecords...)
+			}
+		}
+	}
+
+	return resource, records
+}
+
+func otlpAttributeMap(attributes []*commonpb.KeyValue) map[string]*commonpb.AnyValue {
+	result := map[string]*commonpb.AnyValue{}
+	for _, attribute := range attributes {
+		result[attribute.Key] = attribute.Value
+	}
+
+	return result
+}
+
+// TestConfig_OTLP 使用本地的HTTP采集器测试通过配置导出，并验证可重试错误会重试
+func TestConfig_OTLP(t *testing.T) {
+	var (
+		collector = &fakeCollector{}
+		failures  atomic.Int32
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, `/v1/logs`, r.URL.Path)
+		require.Equal(t, `application/x-protobuf`, r.Header.Get(`Content-Type`))
+
+		// 第一次返回可重试的错误
+		if failures.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		request := &collectorlogs.ExportLogsServiceRequest{}
+		require.NoError(t, proto.Unmarshal(data, request))
+		collector.add(request, r.Header.Get(`X-Token`))
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		Service:     "test",
+		Level:       zapcore.InfoLevel,
+		HideConsole: true,
+		OTLP: &OTLPConfig{
+			Endpoint:      server.URL,
+			Headers:       map[string]string{`X-Token`: `t`},
+			Resource:      map[string]string{`deployment.environment`: `dev`},
+			RetryInterval: 10,
+		},
+	}
+
+	root, err := cfg.Build()
+	require.NoError(t, err)
+
+	root.Debug(`不可见`)
+	root.Derive(`sub`).With(zap.String(`trace_id`, `4bf92f3577b34da6a3ce929d0e0e4736`)).
+		Warn(`警告`, zap.Int(`a`, 1), zap.Bool(`b`, true), zap.Any(`c`, map[string]interface{}{`d`: `e`}))
+	require.NoError(t, root.Sync())
+
+	resource, records := collector.records()
+	require.Equal(t, map[string]string{`service.name`: `test`, `deployment.environment`: `dev`}, resource)
+	require.Equal(t, []string{`t`}, collector.headers)
+	require.Len(t, records, 1)
+
+	record := records[0]
+	require.Equal(t, `警告`, record.Body.GetStringValue())
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_WARN, record.SeverityNumber)
+	require.Equal(t, `WARN`, record.SeverityText)
+	require.Len(t, record.TraceId, 16)
+
+	attributes := otlpAttributeMap(record.Attributes)
+	require.Equal(t, `test`, attributes[`系统`].GetStringValue())
+	require.Equal(t, `sub`, attributes[`logger.name`].GetStringValue())
+	require.EqualValues(t, 1, attributes[`a`].GetIntValue())
+	require.True(t, attributes[`b`].GetBoolValue())
+	require.Equal(t, `e`, attributes[`c`].GetKvlistValue().Values[0].Value.GetStringValue())
+	require.Contains(t, attributes, `code.filepath`)
+	require.NotContains(t, attributes, `trace_id`)
+
+	require.NoError(t, root.Close())
+}
+
+// TestOTLPCore_GRPC 使用进程内的gRPC采集器测试导出及导出失败
+func TestOTLPCore_GRPC(t *testing.T) {
+	collector := &fakeCollector{}
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	collectorlogs.RegisterLogsServiceServer(server, collector)
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	defer server.Stop()
+
+	config := &OTLPConfig{Endpoint: `passthrough:///bufnet`, Protocol: OTLPGRPC, Insecure: true, Headers: map[string]string{`x-token`: `g`}}
+	require.NoError(t, config.tidy())
+
+	exporter, err := newOTLPGRPCExporter(config, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}))
+	require.NoError(t, err)
+
+	core := newOTLPCore(config, `grpc`, exporter)
+	logger := zap.New(core).With(zap.String(`k`, `v`))
+
+	for i := 0; i < 3; i++ {
+		logger.Error(`错误`)
+	}
+
+	require.NoError(t, logger.Sync())
+
+	resource, records := collector.records()
+	require.Equal(t, `grpc`, resource[`service.name`])
+	require.Len(t, records, 3)
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, records[0].SeverityNumber)
+	require.Equal(t, `v`, otlpAttributeMap(records[0].Attributes)[`k`].GetStringValue())
+	require.Equal(t, []string{`g`}, collector.headers[:1])
+	require.Equal(t, OTLPStats{Exported: 3}, core.Stats())
+
+	t.Run("不重试时导出失败", func(t *testing.T) {
+		server.Stop()
+
+		config := &OTLPConfig{Endpoint: `http://127.0.0.1:1`, MaxRetries: -1}
+		core, err := NewOTLPCore(config, `test`)
+		require.NoError(t, err)
+
+		zap.New(core).Info(`失败`)
+		require.Error(t, core.Sync())
+		require.Equal(t, OTLPStats{Failed: 1}, core.Stats())
+		require.NoError(t, core.Close())
+		require.Error(t, core.Write(zapcore.Entry{}, nil))
+	})
+
+	require.NoError(t, core.Close())
+}
@@ -38,19 +38,19 @@ func (p pulsarLogger) WithError(err error) log.Entry {
 }
 
 func (p pulsarLogger) Debug(args ...interface{}) {
-	p.Logger.Debug(``, zap.Any(`参数`, args))
+	p.Logger.Debug(``, zap.Any(fieldNamesOf(p.Logger)[FieldArgs], args))
 }
 
 func (p pulsarLogger) Info(args ...interface{}) {
-	p.Logger.Info(``, zap.Any(`参数`, args))
+	p.Logger.Info(``, zap.Any(fieldNamesOf(p.Logger)[FieldArgs], args))
 }
 
 func (p pulsarLogger) Warn(args ...interface{}) {
-	p.Logger.Warn(``, zap.Any(`参数`, args))
+	p.Logger.Warn(``, zap.Any(fieldNamesOf(p.Logger)[FieldArgs], args))
 }
 
 func (p pulsarLogger) Error(args ...interface{}) {
-	p.Logger.Error(``, zap.Any(`参数`, args))
+	p.Logger.Error(``, zap.Any(fieldNamesOf(p.Logger)[FieldArgs], args))
 }
 
 func (p pulsarLogger) Debugf(format string, args ...interface{}) {
@@ -0,0 +1,361 @@
+package log2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MaskStrategy 脱敏方式
+type MaskStrategy string
+
+const (
+	// MaskFull 全部替换为*
+	MaskFull = MaskStrategy(`full`)
+	// MaskPartial 保留首尾部分字符，中间替换为*
+	MaskPartial = MaskStrategy(`partial`)
+	// MaskHash 替换为sha256摘要的前16位
+	MaskHash = MaskStrategy(`hash`)
+)
+
+const (
+	maskText = `******`
+)
+
+// RedactRule 正则脱敏规则，作用于消息及所有字符串值
+type RedactRule struct {
+	Pattern  string       `yaml:"pattern"`  // 正则，有分组时只脱敏分组的内容，如password=(\S+)
+	Strategy MaskStrategy `yaml:"strategy"` // 脱敏方式，为空时使用RedactConfig.Strategy
+	regexp   *regexp.Regexp
+}
+
+// RedactPathRule 结构化值的路径脱敏规则
+type RedactPathRule struct {
+	Path     string       `yaml:"path"`     // 以字段名开头，.分隔，*匹配任意键或数组元素，如command.filter.phone
+	Strategy MaskStrategy `yaml:"strategy"` // 脱敏方式，为空时使用RedactConfig.Strategy
+	segments []string
+}
+
+// RedactConfig 敏感信息脱敏配置，对所有输出生效
+type RedactConfig struct {
+	Strategy MaskStrategy     `yaml:"strategy"` // 默认脱敏方式，full/partial/hash，默认full
+	Fields   []string         `yaml:"fields"`   // 字段名黑名单，不区分大小写，结构化值内的同名键同样脱敏
+	Rules    []RedactRule     `yaml:"rules"`    // 正则规则
+	Paths    []RedactPathRule `yaml:"paths"`    // 路径规则，内容为json的字符串字段同样生效
+	fields   map[string]bool
+}
+
+func (c *RedactConfig) tidy() (err error) {
+	if c.Strategy == `` {
+		c.Strategy = MaskFull
+	}
+
+	if err = checkMaskStrategy(c.Strategy); err != nil {
+		return err
+	}
+
+	c.fields = make(map[string]bool, len(c.Fields))
+	for _, field := range c.Fields {
+		c.fields[strings.ToLower(field)] = true
+	}
+
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+
+		if rule.Strategy == `` {
+			rule.Strategy = c.Strategy
+		}
+
+		if err = checkMaskStrategy(rule.Strategy); err != nil {
+			return err
+		}
+
+		if rule.regexp, err = regexp.Compile(rule.Pattern); err != nil {
+			return errors.Wrapf(err, `解析正则[%s]`, rule.Pattern)
+		}
+	}
+
+	for i := range c.Paths {
+		rule := &c.Paths[i]
+
+		if rule.Strategy == `` {
+			rule.Strategy = c.Strategy
+		}
+
+		if err = checkMaskStrategy(rule.Strategy); err != nil {
+			return err
+		}
+
+		rule.segments = strings.Split(strings.TrimPrefix(rule.Path, `$.`), `.`)
+	}
+
+	return nil
+}
+
+func checkMaskStrategy(strategy MaskStrategy) error {
+	switch strategy {
+	case MaskFull, MaskPartial, MaskHash:
+		return nil
+	default:
+		return errors.Errorf(`未知的脱敏方式[%s]`, strategy)
+	}
+}
+
+/*
+mask 按脱敏方式处理文本
+参数:
+*	text    	string      	原文
+*	strategy	MaskStrategy	脱敏方式
+返回值:
+*	string  	string      	脱敏后的文本
+*/
+func mask(text string, strategy MaskStrategy) string {
+	switch strategy {
+	case MaskHash:
+		sum := sha256.Sum256([]byte(text))
+
+		return `sha256:` + hex.EncodeToString(sum[:])[:16]
+	case MaskPartial:
+		runes := []rune(text)
+		keep := len(runes) / 4
+
+		if keep == 0 {
+			return maskText
+		}
+
+		return string(runes[:keep]) + strings.Repeat(`*`, len(runes)-2*keep) + string(runes[len(runes)-keep:])
+	default:
+		return maskText
+	}
+}
+
+// redactText 使用正则规则脱敏文本
+func (c *RedactConfig) redactText(text string) string {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+
+		text = rule.regexp.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.regexp.NumSubexp() == 0 {
+				return mask(match, rule.Strategy)
+			}
+
+			// 只替换分组的内容
+			indexes := rule.regexp.FindStringSubmatchIndex(match)
+			result := strings.Builder{}
+			last := 0
+
+			for group := 1; group <= rule.regexp.NumSubexp(); group++ {
+				start, end := indexes[2*group], indexes[2*group+1]
+				if start < 0 || start < last {
+					continue
+				}
+
+				result.WriteString(match[last:start])
+				result.WriteString(mask(match[start:end], rule.Strategy))
+				last = end
+			}
+
+			result.WriteString(match[last:])
+
+			return result.String()
+		})
+	}
+
+	return text
+}
+
+// redactValue 递归脱敏结构化值，path为从字段名开始的路径
+func (c *RedactConfig) redactValue(path []string, value interface{}) interface{} {
+	if len(path) > 0 && c.fields[strings.ToLower(path[len(path)-1])] {
+		return mask(fmt.Sprint(value), c.Strategy)
+	}
+
+	for i := range c.Paths {
+		if matchRedactPath(c.Paths[i].segments, path) {
+			return mask(fmt.Sprint(value), c.Paths[i].Strategy)
+		}
+	}
+
+	switch typed := value.(type) {
+	case string:
+		return c.redactString(path, typed)
+	case map[string]interface{}:
+		for key, item := range typed {
+			typed[key] = c.redactValue(append(path[:len(path):len(path)], key), item)
+		}
+	case []interface{}:
+		for index, item := range typed {
+			typed[index] = c.redactValue(append(path[:len(path):len(path)], fmt.Sprint(index)), item)
+		}
+	}
+
+	return value
+}
+
+// redactString 脱敏字符串，内容为json且有路径规则时按结构化值处理
+func (c *RedactConfig) redactString(path []string, text string) string {
+	trimmed := strings.TrimSpace(text)
+	if len(c.Paths) > 0 && (strings.HasPrefix(trimmed, `{`) || strings.HasPrefix(trimmed, `[`)) {
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		decoder.UseNumber()
+
+		var value interface{}
+		if decoder.Decode(&value) == nil && !decoder.More() {
+			if data, err := json.Marshal(c.redactValue(path, value)); err == nil {
+				return c.redactText(string(data))
+			}
+		}
+	}
+
+	return c.redactText(text)
+}
+
+func matchRedactPath(segments, path []string) bool {
+	if len(segments) != len(path) {
+		return false
+	}
+
+	for i := range segments {
+		if segments[i] != `*` && segments[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+redactFields 脱敏字段
+参数:
+*	fields	[]zapcore.Field	原字段，不会被修改
+返回值:
+*	[]zapcore.Field	[]zapcore.Field	脱敏后的字段
+*/
+func (c *RedactConfig) redactFields(fields []zapcore.Field) []zapcore.Field {
+	if len(fields) == 0 {
+		return fields
+	}
+
+	result := make([]zapcore.Field, len(fields))
+
+	for i, field := range fields {
+		result[i] = c.redactField(field)
+	}
+
+	return result
+}
+
+func (c *RedactConfig) redactField(field zapcore.Field) zapcore.Field {
+	switch field.Type {
+	case zapcore.NamespaceType, zapcore.SkipType:
+		return field
+	case zapcore.StringType:
+		return zap.String(field.Key, c.redactValue([]string{field.Key}, field.String).(string))
+	case zapcore.ByteStringType:
+		return zap.String(field.Key, c.redactValue([]string{field.Key}, string(field.Interface.([]byte))).(string))
+	case zapcore.ErrorType:
+		if c.fields[strings.ToLower(field.Key)] {
+			return zap.String(field.Key, mask(field.Interface.(error).Error(), c.Strategy))
+		}
+
+		message := field.Interface.(error).Error()
+		if redacted := c.redactText(message); redacted != message {
+			return zap.String(field.Key, redacted)
+		}
+
+		return field
+	case zapcore.StringerType:
+		// 通过encoder渲染，沿用zap对nil等情况的处理
+		encoder := zapcore.NewMapObjectEncoder()
+		field.AddTo(encoder)
+
+		if text, ok := encoder.Fields[field.Key].(string); ok {
+			if redacted := c.redactValue([]string{field.Key}, text).(string); redacted != text {
+				return zap.String(field.Key, redacted)
+			}
+		}
+
+		return field
+	}
+
+	path := []string{field.Key}
+
+	// 数字、时间等类型只按字段名及路径整体脱敏，原样保留类型
+	if !c.fields[strings.ToLower(field.Key)] && !c.matchPath(path) {
+		switch field.Type {
+		case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.ReflectType:
+		default:
+			return field
+		}
+	}
+
+	// 结构化值转为通用结构再脱敏
+	encoder := zapcore.NewMapObjectEncoder()
+	field.AddTo(encoder)
+
+	value, exist := encoder.Fields[field.Key]
+	if !exist {
+		return field
+	}
+
+	if data, err := json.Marshal(value); err == nil {
+		// 保留数字原文，避免大整数丢失精度
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+
+		var generic interface{}
+		if decoder.Decode(&generic) == nil {
+			value = generic
+		}
+	}
+
+	return zap.Any(field.Key, c.redactValue(path, value))
+}
+
+// matchPath 是否有路径规则匹配
+func (c *RedactConfig) matchPath(path []string) bool {
+	for i := range c.Paths {
+		if matchRedactPath(c.Paths[i].segments, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactCore 对消息及字段脱敏后写入底层的core
+type redactCore struct {
+	zapcore.Core
+	config *RedactConfig
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{Core: c.Core.With(c.config.redactFields(fields)), config: c.config}
+}
+
+func (c redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = c.config.redactText(entry.Message)
+
+	// 底层可能是按级别区分输出的tee，需要重新Check
+	if checked := c.Core.Check(entry, nil); checked != nil {
+		checked.Write(c.config.redactFields(fields)...)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package log2
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestMask 测试脱敏方式
+func TestMask(t *testing.T) {
+	require.Equal(t, `******`, mask(`13800138000`, MaskFull))
+	require.Equal(t, `138*******000`, mask(`1380013800000`, MaskPartial))
+	require.Equal(t, `******`, mask(`abc`, MaskPartial))
+	require.Equal(t, mask(`token`, MaskHash), mask(`token`, MaskHash))
+	require.NotEqual(t, mask(`token`, MaskHash), mask(`token2`, MaskHash))
+	require.Len(t, mask(`token`, MaskHash), len(`sha256:`)+16)
+}
+
+// TestConfig_Redact 测试通过配置对消息、字段及结构化值脱敏
+func TestConfig_Redact(t *testing.T) {
+	cfg := &Config{
+		Service:     "test",
+		HideConsole: true,
+		Redact: &RedactConfig{
+			Fields: []string{`Password`},
+			Rules: []RedactRule{
+				{Pattern: `1[3-9]\d{9}`, Strategy: MaskPartial},
+				{Pattern: `token=(\w+)`, Strategy: MaskHash},
+			},
+			Paths: []RedactPathRule{{Path: `command.filter.idCard`}, {Path: `$.user.cards.*.no`}},
+		},
+	}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	root.With(zap.String(`password`, `123456`)).Info(`手机13800138000登录`,
+		zap.String(`SQL`, `SELECT * FROM user WHERE phone = '13900139000' AND token=abc`),
+		zap.Int(`PASSWORD`, 123456),
+		zap.String(`command`, `{"find":"user","filter":{"idCard":"110101199001011234","phone":"13700137000"}}`),
+		zap.Any(`user`, map[string]interface{}{
+			`id`:       int64(4611686018427387905),
+			`name`:     `a`,
+			`password`: `x`,
+			`cards`:    []map[string]string{{`no`: `6222000011112222`}},
+		}),
+		zap.Duration(`elapsed`, 1500*time.Microsecond),
+		zap.Int64(`big`, 4611686018427387905),
+		zap.Time(`at`, at),
+		zap.Stringer(`url`, &url.URL{Scheme: `http`, Host: `h`, Path: `/13600136000`, RawQuery: `token=abc`}),
+		zap.Stringer(`Password`, &url.URL{Scheme: `http`, Host: `h`}),
+		zap.NamedError(`cause`, errors.New(`手机13500135000不存在`)),
+	)
+
+	require.Equal(t, 1, recorded.Len())
+	entry := recorded.All()[0]
+	fields := entry.ContextMap()
+
+	require.Equal(t, `手机13*******00登录`, entry.Message)
+	require.Equal(t, `******`, fields[`password`])
+	require.Equal(t, `******`, fields[`PASSWORD`])
+	require.Equal(t, `SELECT * FROM user WHERE phone = '13*******00' AND token=`+mask(`abc`, MaskHash), fields[`SQL`])
+	require.JSONEq(t, `{"find":"user","filter":{"idCard":"******","phone":"13*******00"}}`, fields[`command`].(string))
+	require.Equal(t, map[string]interface{}{
+		`id`:       json.Number(`4611686018427387905`),
+		`name`:     `a`,
+		`password`: `******`,
+		`cards`:    []interface{}{map[string]interface{}{`no`: `******`}},
+	}, fields[`user`])
+
+	// 不在黑名单及路径规则中的字段原样保留类型
+	require.Equal(t, 1500*time.Microsecond, fields[`elapsed`])
+	require.Equal(t, int64(4611686018427387905), fields[`big`])
+	require.Equal(t, at, fields[`at`])
+
+	// Stringer及错误渲染后按规则脱敏
+	require.Equal(t, `http://h/13*******00?token=`+mask(`abc`, MaskHash), fields[`url`])
+	require.Equal(t, `******`, fields[`Password`])
+	require.Equal(t, `手机13*******00不存在`, fields[`cause`])
+
+	t.Run("非法配置", func(t *testing.T) {
+		_, err := (&Config{Service: "test", Redact: &RedactConfig{Rules: []RedactRule{{Pattern: `(`}}}}).Build()
+		require.Error(t, err)
+
+		_, err = (&Config{Service: "test", Redact: &RedactConfig{Strategy: `x`}}).Build()
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,353 @@
+package log2
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultRetentionInterval = 60000
+	// retentionName 删除日志时输出的日志器名称
+	retentionName = `retention`
+)
+
+// RetentionConfig 所有日志文件(Config.FilePath、LevelToPath及Routes)合计的磁盘配额
+type RetentionConfig struct {
+	MaxTotalSize int `yaml:"maxTotalSize"` // 当前文件及切分出的文件合计的最大大小，单位为MB，0为不限制
+	MinFreeSpace int `yaml:"minFreeSpace"` // 日志所在磁盘的最小剩余空间，单位为MB，0为不限制
+	Interval     int `yaml:"interval"`     // 检查间隔,单位为毫秒，默认60000
+}
+
+func (c *RetentionConfig) tidy() {
+	if c.Interval <= 0 {
+		c.Interval = defaultRetentionInterval
+	}
+}
+
+// retentionSource 一个日志文件及其切分出的文件
+type retentionSource interface {
+	// archives 切分出的文件，不包含当前文件
+	archives() ([]rotatedFile, error)
+	// currentPath 当前写入的文件，未打开时为空
+	currentPath() string
+	// compress 切分出的文件是否压缩
+	compress() bool
+}
+
+func (w *rotateWriter) currentPath() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.current
+}
+
+func (w *rotateWriter) compress() bool {
+	return w.config.compression() != CompressionNone
+}
+
+// lumberjackSource lumberjack写入的文件，切分出的文件名为name-2006-01-02T15-04-05.000.log
+type lumberjackSource struct {
+	logger  *lumberjack.Logger
+	codec   CompressionType
+	matcher *regexp.Regexp
+}
+
+func newLumberjackSource(logger *lumberjack.Logger, codec CompressionType) *lumberjackSource {
+	name := filepath.Base(logger.Filename)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + `-`
+
+	return &lumberjackSource{
+		logger: logger,
+		codec:  codec,
+		matcher: regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3}` +
+			regexp.QuoteMeta(ext) + compressedSuffix + `$`),
+	}
+}
+
+func (s *lumberjackSource) compress() bool {
+	return s.codec != CompressionNone
+}
+
+func (s *lumberjackSource) currentPath() string {
+	return s.logger.Filename
+}
+
+func (s *lumberjackSource) archives() ([]rotatedFile, error) {
+	dir := filepath.Dir(s.logger.Filename)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, `读取日志目录[%s]`, dir)
+	}
+
+	var result []rotatedFile
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !s.matcher.MatchString(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		result = append(result, rotatedFile{path: filepath.Join(dir, entry.Name()), info: info})
+	}
+
+	return result, nil
+}
+
+// busyFiles 正在压缩或者等待上传的文件，清理过期文件及检查磁盘配额时跳过，为空时不跳过任何文件
+type busyFiles struct {
+	lock  sync.Mutex
+	paths map[string]int
+}
+
+func newBusyFiles() *busyFiles {
+	return &busyFiles{paths: make(map[string]int)}
+}
+
+// add 标记文件正在使用
+func (b *busyFiles) add(paths ...string) {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, path := range paths {
+		b.paths[path]++
+	}
+}
+
+// done 取消add的标记
+func (b *busyFiles) done(paths ...string) {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, path := range paths {
+		if b.paths[path]--; b.paths[path] <= 0 {
+			delete(b.paths, path)
+		}
+	}
+}
+
+// busy 文件是否正在使用
+func (b *busyFiles) busy(path string) bool {
+	if b == nil {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.paths[path] > 0
+}
+
+/*
+removeMissing 删除记录中已不存在的文件，如已被清理或者上传后删除的文件，避免记录只增不减
+参数:
+*	lock 	sync.Locker    	保护paths的锁，检查文件时不持有
+*	paths	map[string]bool	文件记录
+返回值:
+*	int	int	删除的条数
+*/
+func removeMissing(lock sync.Locker, paths map[string]bool) int {
+	lock.Lock()
+	candidates := make([]string, 0, len(paths))
+	for path := range paths {
+		candidates = append(candidates, path)
+	}
+	lock.Unlock()
+
+	var missing []string
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			missing = append(missing, path)
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, path := range missing {
+		delete(paths, path)
+	}
+
+	return len(missing)
+}
+
+// retention 定期检查磁盘配额，超出时从最早的切分文件开始删除，当前文件不会被删除
+type retention struct {
+	config  *RetentionConfig
+	sources []retentionSource
+	logger  *zap.Logger
+	free    func(dir string) (int64, error) // 目录所在磁盘的剩余空间
+	busy    *busyFiles                      // 正在压缩或者等待上传的文件，不删除
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	fileKey, bytesKey, reasonKey, errorKey string // 删除记录使用的字段名
+}
+
+/*
+newRetention 新建磁盘配额检查，start后开始定期检查
+参数:
+*	config 	*RetentionConfig 	配置
+*	sources	[]retentionSource	所有日志文件
+*	logger 	*zap.Logger      	输出删除记录的日志器
+*	names  	map[string]string	内置字段的名称
+返回值:
+*	*retention	*retention	配额检查
+*/
+func newRetention(config *RetentionConfig, sources []retentionSource, logger *zap.Logger, names map[string]string) *retention {
+	result := &retention{
+		config:  config,
+		sources: sources,
+		logger:  logger,
+		free:    freeSpace,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+
+		fileKey:   names[FieldFile],
+		bytesKey:  names[FieldBytes],
+		reasonKey: names[FieldReason],
+		errorKey:  names[FieldError],
+	}
+
+	return result
+}
+
+// start 在后台定期检查
+func (r *retention) start() {
+	go r.run()
+}
+
+func (r *retention) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(time.Duration(r.config.Interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		r.enforce()
+
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+enforce 检查一次配额，按修改时间从最早的切分文件开始删除
+返回值:
+*	[]string	[]string	删除的文件
+*/
+func (r *retention) enforce() []string {
+	var (
+		files []rotatedFile
+		total int64
+	)
+
+	for _, source := range r.sources {
+		archives, err := source.archives()
+		if err != nil {
+			r.logger.Warn(`获取切分出的日志文件失败`, zap.NamedError(r.errorKey, err))
+
+			continue
+		}
+
+		files = append(files, archives...)
+
+		for i := range archives {
+			total += archives[i].info.Size()
+		}
+
+		if current := source.currentPath(); current != `` {
+			if info, err := os.Stat(current); err == nil {
+				total += info.Size()
+			}
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].info.ModTime().Before(files[j].info.ModTime())
+	})
+
+	maxTotal := int64(r.config.MaxTotalSize) * 1024 * 1024
+	minFree := int64(r.config.MinFreeSpace) * 1024 * 1024
+
+	var removed []string
+
+	for _, file := range files {
+		var reason string
+
+		if r.busy.busy(file.path) {
+			continue
+		}
+
+		switch {
+		case maxTotal > 0 && total > maxTotal:
+			reason = `超过日志总大小限制`
+		case minFree > 0 && r.lowSpace(filepath.Dir(file.path), minFree):
+			reason = `磁盘剩余空间不足`
+		default:
+			continue
+		}
+
+		if err := os.Remove(file.path); err != nil {
+			r.logger.Warn(`删除日志文件失败`, zap.String(r.fileKey, file.path), zap.NamedError(r.errorKey, err))
+
+			continue
+		}
+
+		total -= file.info.Size()
+		removed = append(removed, file.path)
+
+		r.logger.Warn(`删除日志文件`, zap.String(r.fileKey, file.path), zap.Int64(r.bytesKey, file.info.Size()), zap.String(r.reasonKey, reason))
+	}
+
+	return removed
+}
+
+func (r *retention) lowSpace(dir string, minFree int64) bool {
+	free, err := r.free(dir)
+	if err != nil {
+		debugPrintln(`获取磁盘剩余空间`, dir, err)
+
+		return false
+	}
+
+	return free < minFree
+}
+
+// Close 停止检查
+func (r *retention) Close() error {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+
+	<-r.done
+
+	return nil
+}
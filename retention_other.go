@@ -0,0 +1,12 @@
+//go:build !unix
+
+package log2
+
+import (
+	"github.com/pkg/errors"
+)
+
+// freeSpace 当前平台不支持获取剩余空间，MinFreeSpace不生效
+func freeSpace(dir string) (int64, error) {
+	return 0, errors.Errorf(`当前平台不支持获取目录[%s]所在磁盘的剩余空间`, dir)
+}
@@ -0,0 +1,114 @@
+package log2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// writeArchive 写入指定大小及修改时间的文件
+func writeArchive(t *testing.T, path string, size int, modTime time.Time) {
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat(`a`, size)), 0o644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// TestRetention_Enforce 测试跨文件按修改时间从最早的切分文件开始删除
+func TestRetention_Enforce(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	mb := 1024 * 1024
+
+	// 主文件使用lumberjack，按级别的文件使用按时间切分
+	writeArchive(t, filepath.Join(dir, `app.log`), mb/2, base.Add(10*time.Minute))
+	writeArchive(t, filepath.Join(dir, `app-2024-01-01T00-00-00.000.log.gz`), mb/2, base)
+	writeArchive(t, filepath.Join(dir, `app-2024-01-02T00-00-00.000.log.gz`), mb/2, base.Add(2*time.Minute))
+	writeArchive(t, filepath.Join(dir, `error-2024-01-01.log.gz`), mb/2, base.Add(time.Minute))
+	writeArchive(t, filepath.Join(dir, `error-2024-01-02.log`), mb/2, base.Add(3*time.Minute))
+	writeArchive(t, filepath.Join(dir, `other.log`), mb, base.Add(-time.Hour))
+
+	errorWriter := newRotateWriter(filepath.Join(dir, `error.log`), `test`, &RotateConfig{Policy: RotateByTime}, time.UTC)
+	errorWriter.current = filepath.Join(dir, `error-2024-01-02.log`)
+
+	sources := []retentionSource{newLumberjackSource(&lumberjack.Logger{Filename: filepath.Join(dir, `app.log`)}, CompressionGzip), errorWriter}
+
+	core, recorded := observer.New(zapcore.DebugLevel)
+	manager := newRetention(&RetentionConfig{MaxTotalSize: 2}, sources, zap.New(core), zhFieldNames)
+
+	removed := manager.enforce()
+	require.Equal(t, []string{filepath.Join(dir, `app-2024-01-01T00-00-00.000.log.gz`)}, removed, `合计2.5MB，删除最早的一个后为2MB`)
+
+	require.FileExists(t, filepath.Join(dir, `app-2024-01-02T00-00-00.000.log.gz`))
+	require.FileExists(t, filepath.Join(dir, `other.log`), `不属于日志器的文件不删除`)
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, `删除日志文件`, logs[0].Message)
+	require.Equal(t, removed[0], logs[0].ContextMap()[`文件`])
+	require.EqualValues(t, mb/2, logs[0].ContextMap()[`字节数`])
+	require.Equal(t, `超过日志总大小限制`, logs[0].ContextMap()[`原因`])
+
+	// 剩余空间不足时删除到满足为止，当前文件不删除
+	calls := 0
+	manager = newRetention(&RetentionConfig{MinFreeSpace: 1}, sources, zap.New(core), zhFieldNames)
+	manager.free = func(string) (int64, error) {
+		calls++
+		if calls == 1 {
+			return 0, nil
+		}
+
+		return int64(2 * mb), nil
+	}
+
+	removed = manager.enforce()
+	require.Equal(t, []string{filepath.Join(dir, `error-2024-01-01.log.gz`)}, removed)
+	require.FileExists(t, filepath.Join(dir, `app-2024-01-02T00-00-00.000.log.gz`))
+	require.FileExists(t, filepath.Join(dir, `error-2024-01-02.log`))
+	require.FileExists(t, filepath.Join(dir, `app.log`))
+	require.Equal(t, `磁盘剩余空间不足`, recorded.TakeAll()[0].ContextMap()[`原因`])
+
+	// 正在压缩或者等待上传的文件不删除
+	manager = newRetention(&RetentionConfig{MaxTotalSize: 1}, sources, zap.New(core), zhFieldNames)
+	manager.busy = newBusyFiles()
+	manager.busy.add(filepath.Join(dir, `app-2024-01-02T00-00-00.000.log.gz`))
+	require.Empty(t, manager.enforce())
+
+	manager.busy.done(filepath.Join(dir, `app-2024-01-02T00-00-00.000.log.gz`))
+	require.Equal(t, []string{filepath.Join(dir, `app-2024-01-02T00-00-00.000.log.gz`)}, manager.enforce())
+}
+
+// TestConfig_BuildRetention 测试通过配置在后台检查磁盘配额
+func TestConfig_BuildRetention(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, `warn-2024-01-01T00-00-00.000.log.gz`)
+	writeArchive(t, old, 2*1024*1024, time.Now().Add(-time.Hour))
+
+	hook := &bufferHook{minLevel: zapcore.DebugLevel}
+	root, err := (&Config{
+		Service:     `test`,
+		FilePath:    filepath.Join(dir, `app`),
+		HideConsole: true,
+		JSON:        true,
+		LevelToPath: map[string]string{`warn`: filepath.Join(dir, `warn.log`)},
+		Retention:   &RetentionConfig{MaxTotalSize: 1, Interval: 10},
+		Hooks:       []Hook{hook},
+	}).Build()
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(old)
+
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, root.Close())
+	require.Contains(t, hook.String(), `"N":"retention"`)
+	require.Contains(t, hook.String(), `"系统":"test"`)
+}
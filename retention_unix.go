@@ -0,0 +1,19 @@
+//go:build unix
+
+package log2
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// freeSpace 目录所在磁盘非特权用户可用的剩余空间，单位为字节
+func freeSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, errors.Wrapf(err, `获取目录[%s]所在磁盘信息`, dir)
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), nil //nolint:unconvert // 不同平台的类型不同
+}
@@ -0,0 +1,628 @@
+package log2
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// RotatePolicy 日志文件切分策略
+type RotatePolicy string
+
+const (
+	// RotateBySize 按大小切分，未配置Pattern时使用lumberjack，与原有行为一致
+	RotateBySize = RotatePolicy(`size`)
+	// RotateByTime 按时间周期切分
+	RotateByTime = RotatePolicy(`time`)
+	// RotateByTimeOrSize 到达时间周期或者超过大小时切分
+	RotateByTimeOrSize = RotatePolicy(`time-or-size`)
+)
+
+// RotateInterval 按时间切分的周期
+type RotateInterval string
+
+const (
+	// RotateDaily 每天切分
+	RotateDaily = RotateInterval(`daily`)
+	// RotateHourly 每小时切分
+	RotateHourly = RotateInterval(`hourly`)
+)
+
+// 文件名模板中的占位符
+const (
+	// PatternService 服务名称,Config.Service
+	PatternService = `{service}`
+	// PatternName 文件名，Config.FilePath、LevelToPath或者Routes中的路径去掉目录及.log
+	PatternName = `{name}`
+	// PatternDate 周期的开始时间，每天为2006-01-02，每小时为2006-01-02-15
+	PatternDate = `{date}`
+	// PatternIndex 同一周期内按大小切分的序号，从0开始
+	PatternIndex = `{index}`
+)
+
+const (
+	// rotateExt 日志文件的扩展名
+	rotateExt = `.log`
+)
+
+var (
+	patternPlaceholder = regexp.MustCompile(`\{[a-z]+\}`)
+)
+
+// rotating 是否需要使用rotateWriter
+func (c *RotateConfig) rotating() bool {
+	return c.Pattern != `` || (c.Policy != `` && c.Policy != RotateBySize)
+}
+
+func (c *RotateConfig) bySize() bool {
+	return c.Policy == `` || c.Policy == RotateBySize || c.Policy == RotateByTimeOrSize
+}
+
+func (c *RotateConfig) byTime() bool {
+	return c.Policy == RotateByTime || c.Policy == RotateByTimeOrSize
+}
+
+/*
+pattern 实际使用的文件名模板
+返回值:
+*	string	string	模板，未配置时按策略生成
+*/
+func (c *RotateConfig) pattern() string {
+	switch {
+	case c.Pattern != ``:
+		return c.Pattern
+	case c.Policy == RotateByTime:
+		return PatternName + `-` + PatternDate + rotateExt
+	default:
+		return PatternName + `-` + PatternDate + `-` + PatternIndex + rotateExt
+	}
+}
+
+func (c *RotateConfig) dateLayout() string {
+	if c.Interval == RotateHourly {
+		return `2006-01-02-15`
+	}
+
+	return `2006-01-02`
+}
+
+/*
+check 检查切分配置
+参数:
+*	levelFiles	bool 	是否有按级别输出的文件，此时模板需要包含{name}以区分不同文件
+返回值:
+*	error     	error	错误
+*/
+func (c *RotateConfig) check(levelFiles bool) error {
+	switch c.Policy {
+	case ``, RotateBySize, RotateByTime, RotateByTimeOrSize:
+	default:
+		return errors.Errorf(`未知的切分策略[%s]`, c.Policy)
+	}
+
+	switch c.Interval {
+	case ``, RotateDaily, RotateHourly:
+	default:
+		return errors.Errorf(`未知的切分周期[%s]`, c.Interval)
+	}
+
+	if err := c.checkCompression(); err != nil {
+		return err
+	}
+
+	if !c.rotating() {
+		return nil
+	}
+
+	pattern := c.pattern()
+
+	for _, placeholder := range patternPlaceholder.FindAllString(pattern, -1) {
+		switch placeholder {
+		case PatternService, PatternName, PatternDate, PatternIndex:
+		default:
+			return errors.Errorf(`文件名模板[%s]中有未知的占位符%s`, pattern, placeholder)
+		}
+	}
+
+	if strings.ContainsRune(pattern, filepath.Separator) {
+		return errors.Errorf(`文件名模板[%s]不能包含目录`, pattern)
+	}
+
+	if c.bySize() && !strings.Contains(pattern, PatternIndex) {
+		return errors.Errorf(`按大小切分时文件名模板[%s]需要包含%s`, pattern, PatternIndex)
+	}
+
+	if c.byTime() && !strings.Contains(pattern, PatternDate) {
+		return errors.Errorf(`按时间切分时文件名模板[%s]需要包含%s`, pattern, PatternDate)
+	}
+
+	if levelFiles && !strings.Contains(pattern, PatternName) {
+		return errors.Errorf(`配置了LevelToPath或者Routes时文件名模板[%s]需要包含%s`, pattern, PatternName)
+	}
+
+	return nil
+}
+
+// rotateWriter 按时间及大小切分的文件writer，link为指向当前文件的符号链接
+type rotateWriter struct {
+	config   *RotateConfig
+	dir      string
+	link     string // 指向当前文件的符号链接，为空时不创建
+	service  string
+	name     string
+	location *time.Location
+	matcher  *regexp.Regexp // 匹配本writer产生的文件，date组为日期，index组为序号
+	now      func() time.Time
+
+	lock    sync.Mutex
+	file    *os.File
+	current string    // 当前文件路径
+	size    int64     // 当前文件大小
+	period  time.Time // 当前周期的开始时间
+	next    time.Time // 下一个周期的开始时间，不按时间切分时为零值
+	index   int
+
+	millLock   sync.Mutex // 串行执行压缩及清理
+	milling    sync.WaitGroup
+	compressor *compressor       // 共用的压缩协程，为空时在mill中直接压缩
+	onArchived func(path string) // 切分出的文件完成压缩后调用
+	busy       *busyFiles        // 正在压缩或者等待上传的文件，清理时跳过
+}
+
+/*
+newRotateWriter 新建切分文件的writer
+参数:
+*	path    	string        	稳定的文件路径，符号链接建在此处，{name}为其去掉.log的文件名
+*	service 	string        	服务名称
+*	config  	*RotateConfig 	切分配置
+*	location	*time.Location	时区，周期按此时区对齐
+返回值:
+*	*rotateWriter	*rotateWriter	writer
+*/
+func newRotateWriter(path, service string, config *RotateConfig, location *time.Location) *rotateWriter {
+	result := &rotateWriter{
+		config:   config,
+		dir:      filepath.Dir(path),
+		service:  service,
+		name:     strings.TrimSuffix(filepath.Base(path), rotateExt),
+		location: location,
+		now:      time.Now,
+	}
+
+	if !config.DisableSymlink {
+		result.link = path
+	}
+
+	result.matcher = result.newMatcher()
+
+	return result
+}
+
+// newMatcher 将文件名模板转换为正则
+func (w *rotateWriter) newMatcher() *regexp.Regexp {
+	pattern := w.config.pattern()
+	expr := strings.Builder{}
+	expr.WriteString(`^`)
+
+	last := 0
+
+	for _, loc := range patternPlaceholder.FindAllStringIndex(pattern, -1) {
+		expr.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+
+		switch pattern[loc[0]:loc[1]] {
+		case PatternService:
+			expr.WriteString(regexp.QuoteMeta(w.service))
+		case PatternName:
+			expr.WriteString(regexp.QuoteMeta(w.name))
+		case PatternDate:
+			expr.WriteString(`(?P<date>\d{4}-\d{2}-\d{2}(?:-\d{2})?)`)
+		case PatternIndex:
+			expr.WriteString(`(?P<index>\d+)`)
+		}
+
+		last = loc[1]
+	}
+
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString(compressedSuffix + `$`)
+
+	return regexp.MustCompile(expr.String())
+}
+
+// filename 生成指定周期及序号的文件路径
+func (w *rotateWriter) filename(period time.Time, index int) string {
+	name := strings.NewReplacer(
+		PatternService, w.service,
+		PatternName, w.name,
+		PatternDate, period.Format(w.config.dateLayout()),
+		PatternIndex, strconv.Itoa(index),
+	).Replace(w.config.pattern())
+
+	return filepath.Join(w.dir, name)
+}
+
+// periodOf 按时区对齐的周期开始时间
+func (w *rotateWriter) periodOf(now time.Time) time.Time {
+	now = now.In(w.location)
+
+	if w.config.Interval == RotateHourly {
+		return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, w.location)
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, w.location)
+}
+
+func (w *rotateWriter) maxSize() int64 {
+	size := w.config.MaxSize
+	if size <= 0 {
+		size = defaultRotateMaxSize
+	}
+
+	return int64(size) * 1024 * 1024
+}
+
+func (w *rotateWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	now := w.now()
+
+	switch {
+	case w.file == nil:
+		if err := w.open(now); err != nil {
+			return 0, err
+		}
+	case w.config.byTime() && !now.Before(w.next):
+		if err := w.rotate(w.periodOf(now), 0); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.config.bySize() && w.size > 0 && w.size+int64(len(data)) > w.maxSize() {
+		if err := w.rotate(w.period, w.index+1); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+
+	return n, errors.Wrap(err, `写入日志文件`)
+}
+
+// open 首次写入或者关闭后再次写入时打开文件，同一周期内沿用已有的文件
+func (w *rotateWriter) open(now time.Time) error {
+	period := w.periodOf(now)
+	index := 0
+
+	if w.config.bySize() {
+		index = w.lastIndex(period)
+		path := w.filename(period, index)
+
+		// 最后一个文件已压缩或者已写满时使用下一个序号，避免之后压缩时覆盖
+		if compressedPath(path) != `` {
+			index++
+		} else if info, err := os.Stat(path); err == nil && info.Size() >= w.maxSize() {
+			index++
+		}
+	}
+
+	if err := w.openFile(period, index); err != nil {
+		return err
+	}
+
+	w.startMill(``)
+
+	return nil
+}
+
+// lastIndex 目录中指定周期已有文件的最大序号，没有时为0
+func (w *rotateWriter) lastIndex(period time.Time) int {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return 0
+	}
+
+	date := period.Format(w.config.dateLayout())
+	dateGroup, indexGroup := w.matcher.SubexpIndex(`date`), w.matcher.SubexpIndex(`index`)
+	result := 0
+
+	for _, entry := range entries {
+		match := w.matcher.FindStringSubmatch(entry.Name())
+		if match == nil || indexGroup < 0 || dateGroup >= 0 && match[dateGroup] != date {
+			continue
+		}
+
+		if index, _ := strconv.Atoi(match[indexGroup]); index > result {
+			result = index
+		}
+	}
+
+	return result
+}
+
+// rotate 关闭当前文件并打开新文件，之后在后台压缩及清理
+func (w *rotateWriter) rotate(period time.Time, index int) error {
+	archived := w.current
+
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	if err := w.openFile(period, index); err != nil {
+		return err
+	}
+
+	w.startMill(archived)
+
+	return nil
+}
+
+func (w *rotateWriter) openFile(period time.Time, index int) error {
+	if err := os.MkdirAll(w.dir, 0o755); err != nil {
+		return errors.Wrapf(err, `创建日志目录[%s]`, w.dir)
+	}
+
+	path := w.filename(period, index)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, `打开日志文件[%s]`, path)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return errors.Wrapf(err, `获取日志文件[%s]信息`, path)
+	}
+
+	w.file = file
+	w.current = path
+	w.size = info.Size()
+	w.period = period
+	w.index = index
+
+	if w.config.byTime() {
+		if w.config.Interval == RotateHourly {
+			w.next = period.Add(time.Hour)
+		} else {
+			w.next = period.AddDate(0, 0, 1)
+		}
+	}
+
+	w.relink()
+
+	return nil
+}
+
+// relink 将符号链接指向当前文件，链接位置已经是普通文件时不做处理，避免覆盖原有日志
+func (w *rotateWriter) relink() {
+	if w.link == `` || w.link == w.current {
+		return
+	}
+
+	if info, err := os.Lstat(w.link); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		debugPrintln(`符号链接位置已存在文件`, w.link)
+
+		return
+	}
+
+	target, err := filepath.Rel(filepath.Dir(w.link), w.current)
+	if err != nil {
+		target = w.current
+	}
+
+	temp := w.link + `.tmp`
+	_ = os.Remove(temp)
+
+	if err = os.Symlink(target, temp); err != nil {
+		debugPrintln(`创建符号链接`, err)
+
+		return
+	}
+
+	if err = os.Rename(temp, w.link); err != nil {
+		debugPrintln(`替换符号链接`, err)
+
+		_ = os.Remove(temp)
+	}
+}
+
+func (w *rotateWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return errors.Wrap(err, `关闭日志文件`)
+}
+
+func (w *rotateWriter) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	return errors.Wrap(w.file.Sync(), `同步日志文件`)
+}
+
+// Close 关闭当前文件并等待后台压缩及清理完成，之后再次写入会重新打开
+func (w *rotateWriter) Close() error {
+	w.lock.Lock()
+	err := w.closeFile()
+	w.lock.Unlock()
+
+	w.milling.Wait()
+
+	return err
+}
+
+// startMill 在后台压缩及清理，archived为刚切分出的文件，完成后通知onArchived
+func (w *rotateWriter) startMill(archived string) {
+	w.milling.Add(1)
+
+	go func() {
+		defer w.milling.Done()
+
+		if err := w.mill(archived); err != nil {
+			debugPrintln(`压缩及清理日志文件`, err)
+		}
+	}()
+}
+
+// rotatedFile 切分出的文件
+type rotatedFile struct {
+	path  string
+	date  string
+	index int
+	info  os.FileInfo
+}
+
+// archives 按日期及序号从旧到新排列的已切分文件，不包含当前文件
+func (w *rotateWriter) archives() ([]rotatedFile, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, `读取日志目录[%s]`, w.dir)
+	}
+
+	w.lock.Lock()
+	current := w.current
+	w.lock.Unlock()
+
+	dateGroup, indexGroup := w.matcher.SubexpIndex(`date`), w.matcher.SubexpIndex(`index`)
+
+	var result []rotatedFile
+
+	for _, entry := range entries {
+		match := w.matcher.FindStringSubmatch(entry.Name())
+		if match == nil || !entry.Type().IsRegular() {
+			continue
+		}
+
+		file := rotatedFile{path: filepath.Join(w.dir, entry.Name())}
+		if file.path == current {
+			continue
+		}
+
+		if dateGroup >= 0 {
+			file.date = match[dateGroup]
+		}
+
+		if indexGroup >= 0 {
+			file.index, _ = strconv.Atoi(match[indexGroup])
+		}
+
+		if file.info, err = entry.Info(); err != nil {
+			continue
+		}
+
+		result = append(result, file)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].date != result[j].date {
+			return result[i].date < result[j].date
+		}
+
+		return result[i].index < result[j].index
+	})
+
+	return result, nil
+}
+
+// mill 删除超过MaxBackups及MaxAge的文件，再压缩未压缩的文件
+func (w *rotateWriter) mill(archived string) error {
+	w.millLock.Lock()
+	defer w.millLock.Unlock()
+
+	files, err := w.archives()
+	if err != nil {
+		return err
+	}
+
+	remains, err := removeExpired(files, w.config.MaxBackups, w.config.MaxAge, w.now(), w.busy)
+
+	if codec := w.config.compression(); codec != CompressionNone {
+		for _, file := range remains {
+			if compressedExtOf(file.path) != `` {
+				continue
+			}
+
+			_, compressErr := w.compressor.compress(file.path, codec, w.config.CompressionLevel)
+			err = multierr.Append(err, compressErr)
+		}
+	}
+
+	if archived == `` || w.onArchived == nil {
+		return err
+	}
+
+	// 此前的mill可能已经压缩了该文件
+	if compressed := compressedPath(archived); compressed != `` {
+		archived = compressed
+	}
+
+	if _, statErr := os.Stat(archived); statErr == nil {
+		w.onArchived(archived)
+	}
+
+	return err
+}
+
+/*
+removeExpired 删除超过maxBackups及maxAge的文件，正在压缩或者等待上传的文件不删除也不返回
+参数:
+*	files     	[]rotatedFile	从旧到新排列的切分出的文件
+*	maxBackups	int          	最多保留的文件数，0为默认值
+*	maxAge    	int          	最长保留的天数，0为默认值
+*	now       	time.Time    	当前时间
+*	busy      	*busyFiles   	正在压缩或者等待上传的文件
+返回值:
+*	[]rotatedFile	[]rotatedFile	保留的文件
+*	error        	error        	错误
+*/
+func removeExpired(files []rotatedFile, maxBackups, maxAge int, now time.Time, busy *busyFiles) ([]rotatedFile, error) {
+	if maxBackups <= 0 {
+		maxBackups = defaultRotateMaxBackups
+	}
+
+	if maxAge <= 0 {
+		maxAge = defaultRotateMaxAge
+	}
+
+	var (
+		remains []rotatedFile
+		err     error
+	)
+
+	cutoff := now.AddDate(0, 0, -maxAge)
+
+	for i, file := range files {
+		if busy.busy(file.path) {
+			continue
+		}
+
+		if len(files)-i > maxBackups || file.info.ModTime().Before(cutoff) {
+			err = multierr.Append(err, errors.Wrapf(os.Remove(file.path), `删除日志文件[%s]`, file.path))
+
+			continue
+		}
+
+		remains = append(remains, file)
+	}
+
+	return remains, err
+}
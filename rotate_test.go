@@ -0,0 +1,197 @@
+package log2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// clock 可控的时间
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+// TestRotateWriter_Time 测试按小时切分、按时区对齐、压缩及符号链接
+func TestRotateWriter_Time(t *testing.T) {
+	dir := t.TempDir()
+	location := time.FixedZone(`UTC+8`, 8*3600)
+	now := &clock{now: time.Date(2024, 1, 2, 3, 59, 0, 0, time.UTC)}
+
+	writer := newRotateWriter(filepath.Join(dir, `app.log`), `test`, &RotateConfig{Policy: RotateByTime, Interval: RotateHourly}, location)
+	writer.now = now.Now
+
+	var archived []string
+	writer.onArchived = func(path string) { archived = append(archived, path) }
+
+	_, err := writer.Write([]byte("first\n"))
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, `app-2024-01-02-11.log`), `按东八区对齐`)
+
+	now.now = now.now.Add(time.Minute)
+	_, err = writer.Write([]byte("second\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	require.Equal(t, "first\n", readCompressed(t, filepath.Join(dir, `app-2024-01-02-11.log.gz`)))
+	require.NoFileExists(t, filepath.Join(dir, `app-2024-01-02-11.log`))
+	require.Equal(t, []string{filepath.Join(dir, `app-2024-01-02-11.log.gz`)}, archived, `压缩完成后通知`)
+
+	target, err := os.Readlink(filepath.Join(dir, `app.log`))
+	require.NoError(t, err)
+	require.Equal(t, `app-2024-01-02-12.log`, target)
+
+	data, err := os.ReadFile(filepath.Join(dir, `app.log`))
+	require.NoError(t, err)
+	require.Equal(t, "second\n", string(data))
+}
+
+// TestRotateWriter_Size 测试按时间或大小切分，重新打开时跳过写满及已压缩的文件
+func TestRotateWriter_Size(t *testing.T) {
+	dir := t.TempDir()
+	now := &clock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	config := &RotateConfig{Policy: RotateByTimeOrSize, Pattern: `{service}-{date}-{index}.log`, MaxSize: 1, MaxBackups: 2}
+
+	writer := newRotateWriter(filepath.Join(dir, `app.log`), `test`, config, time.UTC)
+	writer.now = now.Now
+
+	chunk := []byte(strings.Repeat(`a`, 600*1024))
+	for i := 0; i < 4; i++ {
+		_, err := writer.Write(chunk)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, writer.Close())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	require.Equal(t, []string{`app.log`, `test-2024-01-02-1.log.gz`, `test-2024-01-02-2.log.gz`, `test-2024-01-02-3.log`}, names, `超过MaxBackups的文件被删除`)
+	require.Len(t, readCompressed(t, filepath.Join(dir, `test-2024-01-02-1.log.gz`)), len(chunk))
+
+	// 同一天重新打开时沿用未写满的文件
+	_, err = writer.Write([]byte("tail\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	info, err := os.Stat(filepath.Join(dir, `test-2024-01-02-3.log`))
+	require.NoError(t, err)
+	require.EqualValues(t, len(chunk)+5, info.Size())
+
+	// 第二天从0开始
+	now.now = now.now.AddDate(0, 0, 1)
+	_, err = writer.Write([]byte("next\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.FileExists(t, filepath.Join(dir, `test-2024-01-03-0.log`))
+}
+
+// TestRotateWriter_KeepRegularFile 符号链接位置已有普通文件时不覆盖
+func TestRotateWriter_KeepRegularFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, `app.log`)
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o644))
+
+	writer := newRotateWriter(path, `test`, &RotateConfig{Policy: RotateByTime}, time.UTC)
+	_, err := writer.Write([]byte("new\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "old\n", string(data))
+}
+
+// TestRemoveExpired 测试按数量及天数删除，正在使用的文件跳过
+func TestRemoveExpired(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	var files []rotatedFile
+
+	for i, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, 24 * time.Hour, time.Hour} {
+		path := filepath.Join(dir, `app-`+string(rune('a'+i))+`.log`)
+		writeArchive(t, path, 1, now.Add(-age))
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+
+		files = append(files, rotatedFile{path: path, info: info})
+	}
+
+	busy := newBusyFiles()
+	busy.add(files[0].path)
+
+	remains, err := removeExpired(files, 2, 30, now, busy)
+	require.NoError(t, err)
+	require.Equal(t, files[2:], remains)
+	require.FileExists(t, files[0].path, `等待上传的文件不删除`)
+	require.NoFileExists(t, files[1].path)
+
+	busy.done(files[0].path)
+
+	remains, err = removeExpired(files[:1], 2, 2, now, busy)
+	require.NoError(t, err)
+	require.Empty(t, remains)
+	require.NoFileExists(t, files[0].path, `超过天数`)
+}
+
+// TestRotateConfig_Check 测试切分配置检查
+func TestRotateConfig_Check(t *testing.T) {
+	require.NoError(t, (&RotateConfig{}).check(true))
+	require.NoError(t, (&RotateConfig{Policy: RotateByTime}).check(true))
+	require.NoError(t, (&RotateConfig{Pattern: `{service}-{index}.log`}).check(false))
+	require.Error(t, (&RotateConfig{Policy: `weekly`}).check(false))
+	require.Error(t, (&RotateConfig{Policy: RotateByTime, Interval: `weekly`}).check(false))
+	require.Error(t, (&RotateConfig{Pattern: `{host}-{index}.log`}).check(false), `未知的占位符`)
+	require.Error(t, (&RotateConfig{Pattern: `{date}.log`}).check(false), `按大小切分需要序号`)
+	require.Error(t, (&RotateConfig{Policy: RotateByTime, Pattern: `{name}.log`}).check(false), `按时间切分需要日期`)
+	require.Error(t, (&RotateConfig{Policy: RotateByTime, Pattern: `{service}-{date}.log`}).check(true), `按级别输出需要名称`)
+}
+
+// TestConfig_BuildRotate 测试通过配置使用按时间切分的文件
+func TestConfig_BuildRotate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		Service:     `test`,
+		FilePath:    filepath.Join(dir, `app`),
+		HideConsole: true,
+		TimeZone:    `UTC`,
+		LevelToPath: map[string]string{`error`: filepath.Join(dir, `error.log`)},
+		Rotate:      &RotateConfig{Policy: RotateByTimeOrSize, Pattern: `{service}-{name}-{date}-{index}.log`},
+	}
+
+	root, err := cfg.Build()
+	require.NoError(t, err)
+
+	root.Info(`信息`)
+	root.Error(`错误`)
+	require.NoError(t, root.Close())
+
+	date := time.Now().UTC().Format(`2006-01-02`)
+
+	data, err := os.ReadFile(filepath.Join(dir, `app.log`))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `信息`)
+	require.FileExists(t, filepath.Join(dir, `test-app-`+date+`-0.log`))
+
+	data, err = os.ReadFile(filepath.Join(dir, `error.log`))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `错误`)
+	require.NotContains(t, string(data), `信息`)
+
+	_, err = (&Config{HideConsole: true, Rotate: &RotateConfig{Policy: `weekly`}}).Build()
+	require.Error(t, err)
+}
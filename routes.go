@@ -0,0 +1,434 @@
+package log2
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+// RouteMode 路由匹配的日志是否还写入主文件
+type RouteMode string
+
+const (
+	// RouteCopy 同时写入路由文件及主文件
+	RouteCopy = RouteMode(`copy`)
+	// RouteMove 只写入路由文件，不再写入主文件(Config.FilePath)，控制台、Hooks及其他路由不受影响
+	RouteMove = RouteMode(`move`)
+)
+
+const (
+	// routeAny 日志器名称匹配任意名称
+	routeAny = `*`
+	// routeRangeSeparator 级别范围的分隔符，如info..error
+	routeRangeSeparator = `..`
+)
+
+// RouteConfig 按级别、日志器名称及字段将日志写入单独的文件，条件之间为且的关系，未配置的条件不限制
+type RouteConfig struct {
+	Path string `yaml:"path"` // 文件路径，如logs/sql.log
+	// Level 级别条件，如warn、>=warn、>info、<=info、<warn、info..error，为空时不限制
+	Level string `yaml:"level"`
+	// Loggers Derive出的日志器名称，满足任意一个即可，gorm.*匹配gorm及其衍生出的日志器，*匹配所有
+	Loggers []string `yaml:"loggers"`
+	// Fields 字段条件，With及调用时的字段都参与匹配，值为*时只要求字段存在
+	Fields        map[string]string `yaml:"fields"`
+	Mode          RouteMode         `yaml:"mode"`          // copy/move，默认copy
+	Format        string            `yaml:"format"`        // 输出格式,console/json/logfmt，默认同Config
+	EncoderPreset string            `yaml:"encoderPreset"` // 编码器预设，默认同Config
+	Rotate        *RotateConfig     `yaml:"rotate"`        // 切分配置，为空时同Config.Rotate
+	matcher       *routeMatcher
+}
+
+// tidy 检查并解析条件
+func (r *RouteConfig) tidy() (err error) {
+	if r.Path == `` {
+		return errors.New(`文件路径不能为空`)
+	}
+
+	switch r.Mode {
+	case ``, RouteCopy, RouteMove:
+	default:
+		return errors.Errorf(`未知的路由模式[%s]`, r.Mode)
+	}
+
+	switch r.Format {
+	case ``, FormatConsole, FormatJSON, FormatLogfmt:
+	default:
+		return errors.Errorf(`未知的输出格式[%s]`, r.Format)
+	}
+
+	if err = checkEncoderPreset(r.EncoderPreset); err != nil {
+		return err
+	}
+
+	matcher := &routeMatcher{fields: r.Fields}
+
+	if matcher.min, matcher.max, err = parseLevelRange(r.Level); err != nil {
+		return err
+	}
+
+	for _, pattern := range r.Loggers {
+		if pattern == `` {
+			return errors.New(`日志器名称不能为空`)
+		}
+
+		matcher.loggers = append(matcher.loggers, pattern)
+	}
+
+	if r.Rotate != nil {
+		// 与其他文件可能在同一目录，需要按名称区分
+		if err = r.Rotate.check(true); err != nil {
+			return errors.Wrap(err, `切分配置`)
+		}
+
+		if r.Rotate.Upload != nil && r.Rotate.Upload.Manifest == `` {
+			name := strings.TrimSuffix(filepath.Base(r.Path), rotateExt)
+			r.Rotate.Upload.Manifest = filepath.Join(filepath.Dir(r.Path), name+`-`+defaultUploadManifest)
+		}
+	}
+
+	r.matcher = matcher
+
+	return nil
+}
+
+/*
+parseLevelRange 解析级别条件
+参数:
+*	text	string       	级别条件，如warn、>=warn、<info、info..error
+返回值:
+*	min 	zapcore.Level	最小级别，包含
+*	max 	zapcore.Level	最大级别，包含
+*	err 	error        	错误
+*/
+func parseLevelRange(text string) (min, max zapcore.Level, err error) {
+	min, max = zapcore.DebugLevel, zapcore.FatalLevel
+	text = strings.TrimSpace(text)
+
+	if text == `` {
+		return min, max, nil
+	}
+
+	parse := func(levelText string) (zapcore.Level, error) {
+		// ParseLevel将空字符串解析为info
+		if levelText = strings.TrimSpace(levelText); levelText == `` {
+			return 0, errors.Errorf(`级别条件[%s]缺少级别`, text)
+		}
+
+		level, err := zapcore.ParseLevel(levelText)
+
+		return level, errors.Wrapf(err, `解析级别条件[%s]`, text)
+	}
+
+	var level zapcore.Level
+
+	switch {
+	case strings.Contains(text, routeRangeSeparator):
+		parts := strings.SplitN(text, routeRangeSeparator, 2)
+
+		if min, err = parse(parts[0]); err != nil {
+			return min, max, err
+		}
+
+		if max, err = parse(parts[1]); err != nil {
+			return min, max, err
+		}
+	case strings.HasPrefix(text, `>=`):
+		min, err = parse(text[2:])
+	case strings.HasPrefix(text, `<=`):
+		max, err = parse(text[2:])
+	case strings.HasPrefix(text, `>`):
+		level, err = parse(text[1:])
+		min = level + 1
+	case strings.HasPrefix(text, `<`):
+		level, err = parse(text[1:])
+		max = level - 1
+	default:
+		level, err = parse(strings.TrimPrefix(text, `=`))
+		min, max = level, level
+	}
+
+	if err != nil {
+		return min, max, err
+	}
+
+	if min > max {
+		return min, max, errors.Errorf(`级别条件[%s]不匹配任何级别`, text)
+	}
+
+	return min, max, nil
+}
+
+// routeMatcher 路由的匹配条件
+type routeMatcher struct {
+	min, max zapcore.Level
+	loggers  []string
+	fields   map[string]string
+}
+
+func (m *routeMatcher) levelEnabled(level zapcore.Level) bool {
+	return level >= m.min && level <= m.max
+}
+
+// matchEntry 匹配级别及日志器名称，字段需要在写入时由matchFields匹配
+func (m *routeMatcher) matchEntry(entry zapcore.Entry) bool {
+	if !m.levelEnabled(entry.Level) {
+		return false
+	}
+
+	if len(m.loggers) == 0 {
+		return true
+	}
+
+	for _, pattern := range m.loggers {
+		if matchLoggerName(pattern, entry.LoggerName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+matchFields 匹配字段条件
+参数:
+*	values	map[string]interface{}	With及调用时的字段编码后的值
+返回值:
+*	bool  	bool                  	是否匹配
+*/
+func (m *routeMatcher) matchFields(values map[string]interface{}) bool {
+	for key, want := range m.fields {
+		value, ok := values[key]
+		if !ok {
+			return false
+		}
+
+		if want != routeAny && fmt.Sprint(value) != want {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+matchLoggerName 日志器名称是否匹配
+参数:
+*	pattern	string	名称，*匹配所有，以.*结尾时匹配其本身及衍生出的日志器，以*结尾时按前缀匹配
+*	name   	string	日志器名称，Derive出的名称以.连接
+返回值:
+*	bool   	bool  	是否匹配
+*/
+func matchLoggerName(pattern, name string) bool {
+	switch {
+	case pattern == routeAny:
+		return true
+	case strings.HasSuffix(pattern, `.`+routeAny):
+		parent := strings.TrimSuffix(pattern, `.`+routeAny)
+
+		return name == parent || strings.HasPrefix(name, parent+`.`)
+	case strings.HasSuffix(pattern, routeAny):
+		return strings.HasPrefix(name, strings.TrimSuffix(pattern, routeAny))
+	default:
+		return name == pattern
+	}
+}
+
+// routeFields With添加的字段，有字段条件时才记录
+type routeFields struct {
+	enabled bool
+	context []zapcore.Field
+}
+
+func (f routeFields) with(fields []zapcore.Field) routeFields {
+	if !f.enabled || len(fields) == 0 {
+		return f
+	}
+
+	context := make([]zapcore.Field, 0, len(f.context)+len(fields))
+	context = append(context, f.context...)
+	context = append(context, fields...)
+
+	return routeFields{enabled: true, context: context}
+}
+
+// values 将With及调用时的字段编码为值
+func (f routeFields) values(fields []zapcore.Field) map[string]interface{} {
+	encoder := zapcore.NewMapObjectEncoder()
+
+	for i := range f.context {
+		f.context[i].AddTo(encoder)
+	}
+
+	for i := range fields {
+		fields[i].AddTo(encoder)
+	}
+
+	return encoder.Fields
+}
+
+// routeCore 只写入匹配路由条件的日志
+type routeCore struct {
+	zapcore.Core
+	matcher *routeMatcher
+	fields  routeFields
+}
+
+func newRouteCore(core zapcore.Core, matcher *routeMatcher) zapcore.Core {
+	return &routeCore{Core: core, matcher: matcher, fields: routeFields{enabled: len(matcher.fields) > 0}}
+}
+
+func (c *routeCore) Enabled(level zapcore.Level) bool {
+	return c.matcher.levelEnabled(level) && c.Core.Enabled(level)
+}
+
+func (c *routeCore) With(fields []zapcore.Field) zapcore.Core {
+	return &routeCore{Core: c.Core.With(fields), matcher: c.matcher, fields: c.fields.with(fields)}
+}
+
+func (c *routeCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.matcher.matchEntry(entry) && c.Core.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c *routeCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if c.fields.enabled && !c.matcher.matchFields(c.fields.values(fields)) {
+		return nil
+	}
+
+	return c.Core.Write(entry, fields)
+}
+
+// movedCore 主文件的core，跳过被move路由匹配的日志
+type movedCore struct {
+	zapcore.Core
+	matchers []*routeMatcher
+	fields   routeFields
+}
+
+/*
+newMovedCore 包装主文件的core
+参数:
+*	core    	zapcore.Core   	主文件的core
+*	matchers	[]*routeMatcher	move路由的条件
+返回值:
+*	zapcore.Core	zapcore.Core	没有move路由时原样返回
+*/
+func newMovedCore(core zapcore.Core, matchers []*routeMatcher) zapcore.Core {
+	if len(matchers) == 0 {
+		return core
+	}
+
+	result := &movedCore{Core: core, matchers: matchers}
+
+	for _, matcher := range matchers {
+		if len(matcher.fields) > 0 {
+			result.fields.enabled = true
+		}
+	}
+
+	return result
+}
+
+func (c *movedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &movedCore{Core: c.Core.With(fields), matchers: c.matchers, fields: c.fields.with(fields)}
+}
+
+func (c *movedCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	for _, matcher := range c.matchers {
+		// 没有字段条件时在Check阶段即可确定
+		if len(matcher.fields) == 0 && matcher.matchEntry(entry) {
+			return checked
+		}
+	}
+
+	if c.Core.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c *movedCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	var values map[string]interface{}
+
+	for _, matcher := range c.matchers {
+		if !matcher.matchEntry(entry) {
+			continue
+		}
+
+		if values == nil {
+			values = c.fields.values(fields)
+		}
+
+		if matcher.matchFields(values) {
+			return nil
+		}
+	}
+
+	return c.Core.Write(entry, fields)
+}
+
+// tidyRoutes 检查路由，路由的文件不能与主文件、LevelToPath及其他路由的文件相同
+func (l *Config) tidyRoutes() error {
+	paths := make(map[string]bool, len(l.levelToPath)+1)
+
+	if l.FilePath != `` {
+		paths[filepath.Clean(l.FilePath+rotateExt)] = true
+	}
+
+	for _, path := range l.levelToPath {
+		paths[filepath.Clean(path)] = true
+	}
+
+	for i := range l.Routes {
+		route := &l.Routes[i]
+
+		if err := route.tidy(); err != nil {
+			return errors.Wrapf(err, `路由[%d]`, i)
+		}
+
+		path := filepath.Clean(route.Path)
+		if paths[path] {
+			return errors.Errorf(`路由[%d]的文件[%s]已被使用`, i, route.Path)
+		}
+
+		paths[path] = true
+	}
+
+	return nil
+}
+
+// movedMatchers move路由的条件
+func (l *Config) movedMatchers() []*routeMatcher {
+	var result []*routeMatcher
+
+	for i := range l.Routes {
+		if l.Routes[i].Mode == RouteMove {
+			result = append(result, l.Routes[i].matcher)
+		}
+	}
+
+	return result
+}
+
+// routeEncoder 路由的编码器，未配置的格式及预设与Config一致
+func (l *Config) routeEncoder(route *RouteConfig) zapcore.Encoder {
+	config := *l
+
+	if route.Format != `` {
+		config.Format = route.Format
+	}
+
+	if route.EncoderPreset != `` {
+		config.EncoderPreset = route.EncoderPreset
+	}
+
+	return newEncoder(config.format(), config.newEncoderConfig(), config.EncoderPreset)
+}
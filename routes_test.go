@@ -0,0 +1,199 @@
+package log2
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLevelRange(t *testing.T) {
+	tests := []struct {
+		text     string
+		min, max zapcore.Level
+	}{
+		{``, zapcore.DebugLevel, zapcore.FatalLevel},
+		{`warn`, zapcore.WarnLevel, zapcore.WarnLevel},
+		{`=warn`, zapcore.WarnLevel, zapcore.WarnLevel},
+		{`>=warn`, zapcore.WarnLevel, zapcore.FatalLevel},
+		{`>warn`, zapcore.ErrorLevel, zapcore.FatalLevel},
+		{`<=info`, zapcore.DebugLevel, zapcore.InfoLevel},
+		{`<info`, zapcore.DebugLevel, zapcore.DebugLevel},
+		{`info..error`, zapcore.InfoLevel, zapcore.ErrorLevel},
+		{` >= error `, zapcore.ErrorLevel, zapcore.FatalLevel},
+	}
+
+	for _, test := range tests {
+		min, max, err := parseLevelRange(test.text)
+		require.NoError(t, err, test.text)
+		require.Equal(t, test.min, min, test.text)
+		require.Equal(t, test.max, max, test.text)
+	}
+
+	for _, text := range []string{`warning!`, `>=`, `error..info`, `<debug`, `>fatal`} {
+		_, _, err := parseLevelRange(text)
+		require.Error(t, err, text)
+	}
+}
+
+func TestMatchLoggerName(t *testing.T) {
+	require.True(t, matchLoggerName(`*`, ``))
+	require.True(t, matchLoggerName(`gorm.*`, `gorm`))
+	require.True(t, matchLoggerName(`gorm.*`, `gorm.user`))
+	require.False(t, matchLoggerName(`gorm.*`, `gormx`))
+	require.True(t, matchLoggerName(`gorm*`, `gormx`))
+	require.True(t, matchLoggerName(`http`, `http`))
+	require.False(t, matchLoggerName(`http`, `http.client`))
+}
+
+// readLines 读取文件中的所有行
+func readLines(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestConfig_BuildRoutes(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := NewConfigFromYamlData(strings.NewReader(`
+service: test
+level: debug
+filePath: ` + filepath.Join(dir, `app`) + `
+hideConsole: true
+timeZone: UTC
+routes:
+  - path: ` + filepath.Join(dir, `sql.log`) + `
+    loggers: [gorm.*]
+    mode: move
+    format: json
+  - path: ` + filepath.Join(dir, `warn.log`) + `
+    level: '>=warn'
+    format: logfmt
+  - path: ` + filepath.Join(dir, `tenant.log`) + `
+    level: info..error
+    fields:
+      tenant: a
+    mode: move
+`))
+	require.NoError(t, err)
+
+	root, err := cfg.Build()
+	require.NoError(t, err)
+
+	root.Derive(`gorm`).Derive(`user`).Info(`查询`, zap.Int(`rows`, 1))
+	root.Derive(`gormx`).Warn(`告警`)
+	root.With(zap.String(`tenant`, `a`)).Info(`租户a`)
+	root.Info(`租户b`, zap.String(`tenant`, `b`))
+	root.Debug(`调试`, zap.String(`tenant`, `a`))
+	require.NoError(t, root.Close())
+
+	lines := readLines(t, filepath.Join(dir, `sql.log`))
+	require.Len(t, lines, 1)
+
+	record := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record), `路由使用自己的编码器`)
+	require.Equal(t, `查询`, record[`M`])
+	require.Equal(t, `gorm.user`, record[`N`])
+
+	lines = readLines(t, filepath.Join(dir, `warn.log`))
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `L=WARN N=gormx`)
+	require.Contains(t, lines[0], `M=告警`)
+
+	lines = readLines(t, filepath.Join(dir, `tenant.log`))
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `租户a`, `With的字段参与匹配`)
+
+	data, err := os.ReadFile(filepath.Join(dir, `app.log`))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), `查询`, `move的日志不写入主文件`)
+	require.NotContains(t, string(data), `租户a`, `move的日志不写入主文件`)
+	require.Contains(t, string(data), `告警`, `copy的日志同时写入主文件`)
+	require.Contains(t, string(data), `租户b`)
+	require.Contains(t, string(data), `调试`, `不满足级别条件时仍写入主文件`)
+}
+
+func TestConfig_BuildRoutesRotate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		Service:     `test`,
+		FilePath:    filepath.Join(dir, `app`),
+		HideConsole: true,
+		TimeZone:    `UTC`,
+		Routes: []RouteConfig{{
+			Path:   filepath.Join(dir, `error.log`),
+			Level:  `>=error`,
+			Mode:   RouteMove,
+			Rotate: &RotateConfig{Policy: RotateByTime, Pattern: `{name}-{date}.log`},
+		}},
+	}
+
+	root, err := cfg.Build()
+	require.NoError(t, err)
+
+	root.Error(`错误`)
+	root.Info(`信息`)
+	require.NoError(t, root.Close())
+
+	matches, err := filepath.Glob(filepath.Join(dir, `error-*.log`))
+	require.NoError(t, err)
+	require.Len(t, matches, 1, `路由使用自己的切分配置`)
+
+	lines := readLines(t, filepath.Join(dir, `error.log`))
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `错误`)
+
+	lines = readLines(t, filepath.Join(dir, `app.log`))
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `信息`)
+}
+
+func TestNewConfigFromToml_Routes(t *testing.T) {
+	cfg, err := NewConfigFromToml([]byte(`
+FilePath = 'logs/app'
+
+[[Routes]]
+Path = 'logs/sql.log'
+Loggers = ['gorm.*']
+Mode = 'move'
+
+[Routes.Rotate]
+Policy = 'time'
+
+[[Routes]]
+Path = 'logs/warn.log'
+Level = '>=warn'
+
+[Routes.Fields]
+tenant = '*'
+`))
+	require.NoError(t, err)
+	require.Equal(t, []RouteConfig{
+		{Path: `logs/sql.log`, Loggers: []string{`gorm.*`}, Mode: RouteMove, Rotate: &RotateConfig{Policy: RotateByTime}},
+		{Path: `logs/warn.log`, Level: `>=warn`, Fields: map[string]string{`tenant`: `*`}},
+	}, cfg.Routes)
+}
+
+func TestConfig_RoutesCheck(t *testing.T) {
+	tests := []Config{
+		{Routes: []RouteConfig{{}}},
+		{Routes: []RouteConfig{{Path: `logs/a.log`, Level: `warning!`}}},
+		{Routes: []RouteConfig{{Path: `logs/a.log`, Mode: `link`}}},
+		{Routes: []RouteConfig{{Path: `logs/a.log`, Format: `xml`}}},
+		{Routes: []RouteConfig{{Path: `logs/a.log`, Loggers: []string{``}}}},
+		{Routes: []RouteConfig{{Path: `logs/a.log`, Rotate: &RotateConfig{Policy: RotateByTime, Pattern: `{date}.log`}}}},
+		{FilePath: `logs/a`, Routes: []RouteConfig{{Path: `logs/a.log`}}},
+		{Routes: []RouteConfig{{Path: `logs/a.log`}, {Path: `logs/./a.log`}}},
+		{Rotate: &RotateConfig{Policy: RotateByTime, Pattern: `{date}.log`}, Routes: []RouteConfig{{Path: `logs/a.log`}}},
+	}
+
+	for i := range tests {
+		require.Error(t, tests[i].tidy(), i)
+	}
+}
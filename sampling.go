@@ -0,0 +1,265 @@
+package log2
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultSamplingTick       = 1000
+	defaultSamplingInitial    = 100
+	defaultSamplingThereafter = 100
+	defaultRateLimitInterval  = 1000
+	defaultRateLimitBurst     = 10
+)
+
+// SamplingLevelConfig 单个级别的采样配置
+type SamplingLevelConfig struct {
+	Initial    int `yaml:"initial"`    // 每个周期内相同消息最先输出的条数
+	Thereafter int `yaml:"thereafter"` // 之后每thereafter条输出一条
+}
+
+// SamplingConfig 采样配置，相同级别及消息的日志在一个周期内超过initial条后按thereafter采样
+type SamplingConfig struct {
+	Tick       int                            `yaml:"tick"`       // 统计周期,单位为毫秒,默认1000
+	Initial    int                            `yaml:"initial"`    // 每个周期内相同消息最先输出的条数,默认100
+	Thereafter int                            `yaml:"thereafter"` // 之后每thereafter条输出一条,默认100
+	Levels     map[string]SamplingLevelConfig `yaml:"levels"`     // 按级别覆盖,key为debug,info,warn,error等
+	levels     map[zapcore.Level]SamplingLevelConfig
+}
+
+func (c *SamplingConfig) tidy() error {
+	if c.Tick <= 0 {
+		c.Tick = defaultSamplingTick
+	}
+
+	if c.Initial <= 0 {
+		c.Initial = defaultSamplingInitial
+	}
+
+	if c.Thereafter <= 0 {
+		c.Thereafter = defaultSamplingThereafter
+	}
+
+	c.levels = make(map[zapcore.Level]SamplingLevelConfig, len(c.Levels))
+
+	for levelText, levelConfig := range c.Levels {
+		level, err := zapcore.ParseLevel(levelText)
+		if err != nil {
+			return errors.Wrapf(err, `解析level[%s]`, levelText)
+		}
+
+		if levelConfig.Initial <= 0 {
+			levelConfig.Initial = c.Initial
+		}
+
+		if levelConfig.Thereafter <= 0 {
+			levelConfig.Thereafter = c.Thereafter
+		}
+
+		c.levels[level] = levelConfig
+	}
+
+	return nil
+}
+
+// samplingCore 按级别使用不同采样器的core
+type samplingCore struct {
+	zapcore.Core
+	defaultSampler zapcore.Core
+	samplers       map[zapcore.Level]zapcore.Core
+}
+
+func newSamplingCore(core zapcore.Core, config *SamplingConfig) zapcore.Core {
+	tick := time.Duration(config.Tick) * time.Millisecond
+	result := &samplingCore{
+		Core:           core,
+		defaultSampler: zapcore.NewSamplerWithOptions(core, tick, config.Initial, config.Thereafter),
+		samplers:       make(map[zapcore.Level]zapcore.Core, len(config.levels)),
+	}
+
+	for level, levelConfig := range config.levels {
+		result.samplers[level] = zapcore.NewSamplerWithOptions(core, tick, levelConfig.Initial, levelConfig.Thereafter)
+	}
+
+	return result
+}
+
+func (c *samplingCore) sampler(level zapcore.Level) zapcore.Core {
+	if sampler, exist := c.samplers[level]; exist {
+		return sampler
+	}
+
+	return c.defaultSampler
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	result := &samplingCore{
+		Core:           c.Core.With(fields),
+		defaultSampler: c.defaultSampler.With(fields),
+		samplers:       make(map[zapcore.Level]zapcore.Core, len(c.samplers)),
+	}
+
+	for level, sampler := range c.samplers {
+		result.samplers[level] = sampler.With(fields)
+	}
+
+	return result
+}
+
+func (c *samplingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.sampler(entry.Level).Check(entry, checked)
+}
+
+// RateLimitConfig 限流配置，相同日志器名称、级别及消息的日志每个周期最多输出burst条，其余的在周期结束时汇总输出一条
+type RateLimitConfig struct {
+	Interval int `yaml:"interval"` // 周期,单位为毫秒,默认1000
+	Burst    int `yaml:"burst"`    // 每个周期内最多输出的条数,默认10
+}
+
+func (c *RateLimitConfig) tidy() {
+	if c.Interval <= 0 {
+		c.Interval = defaultRateLimitInterval
+	}
+
+	if c.Burst <= 0 {
+		c.Burst = defaultRateLimitBurst
+	}
+}
+
+// rateLimitKey 限流的key
+type rateLimitKey struct {
+	name    string
+	level   zapcore.Level
+	message string
+}
+
+// rateLimiter 按消息限流，周期结束时输出被抑制的条数
+type rateLimiter struct {
+	lock       sync.Mutex
+	config     *RateLimitConfig
+	core       zapcore.Core // 输出汇总日志的core，带有服务名称字段
+	messageKey string
+	countKey   string
+	counts     map[rateLimitKey]int
+	suppressed map[rateLimitKey]int
+	done       chan struct{}
+	stopped    chan struct{}
+	once       sync.Once
+}
+
+/*
+newRateLimiter 新建限流，后台按周期输出汇总
+参数:
+*	core  	zapcore.Core    	输出汇总日志的core，需要带有服务名称等公共字段
+*	config	*RateLimitConfig	配置
+*	names 	map[string]string	内置字段的名称
+返回值:
+*	*rateLimiter	*rateLimiter	限流
+*/
+func newRateLimiter(core zapcore.Core, config *RateLimitConfig, names map[string]string) *rateLimiter {
+	result := &rateLimiter{
+		config:     config,
+		core:       core,
+		messageKey: names[FieldMessage],
+		countKey:   names[FieldCount],
+		counts:     make(map[rateLimitKey]int),
+		suppressed: make(map[rateLimitKey]int),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	go result.run()
+
+	return result
+}
+
+func (r *rateLimiter) run() {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(time.Duration(r.config.Interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			r.report()
+
+			return
+		case <-ticker.C:
+			r.report()
+		}
+	}
+}
+
+// allow 判断是否允许输出，不允许时计入被抑制的条数
+func (r *rateLimiter) allow(entry zapcore.Entry) bool {
+	key := rateLimitKey{name: entry.LoggerName, level: entry.Level, message: entry.Message}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.counts[key]++
+	if r.counts[key] <= r.config.Burst {
+		return true
+	}
+
+	r.suppressed[key]++
+
+	return false
+}
+
+// report 开始新周期，输出上个周期被抑制的条数
+func (r *rateLimiter) report() {
+	r.lock.Lock()
+	suppressed := r.suppressed
+	r.counts = make(map[rateLimitKey]int, len(r.counts))
+	r.suppressed = make(map[rateLimitKey]int)
+	r.lock.Unlock()
+
+	for key, count := range suppressed {
+		entry := zapcore.Entry{
+			LoggerName: key.name,
+			Level:      key.level,
+			Time:       time.Now(),
+			Message:    `已抑制相似日志`,
+		}
+
+		if checked := r.core.Check(entry, nil); checked != nil {
+			checked.Write(zap.String(r.messageKey, key.message), zap.Int(r.countKey, count))
+		}
+	}
+}
+
+// Close 停止周期统计，输出最后一个周期的汇总
+func (r *rateLimiter) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+	})
+
+	<-r.stopped
+
+	return nil
+}
+
+// rateLimitCore 按消息限流的core
+type rateLimitCore struct {
+	zapcore.Core
+	limiter *rateLimiter
+}
+
+func (c rateLimitCore) With(fields []zapcore.Field) zapcore.Core {
+	return rateLimitCore{Core: c.Core.With(fields), limiter: c.limiter}
+}
+
+func (c rateLimitCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) || !c.limiter.allow(entry) {
+		return checked
+	}
+
+	return c.Core.Check(entry, checked)
+}
@@ -0,0 +1,110 @@
+package log2
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestConfig_Sampling 测试按级别采样
+func TestConfig_Sampling(t *testing.T) {
+	cfg, err := NewConfigFromToml([]byte(`
+Service = 'test'
+Level = 'debug'
+HideConsole = true
+
+[Sampling]
+Tick = 60000
+Initial = 2
+Thereafter = 3
+
+[Sampling.Levels.debug]
+Initial = 1
+Thereafter = 100
+`))
+	require.NoError(t, err)
+
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		root.Info(`info`)
+		root.With(zap.Int(`i`, i)).Debug(`debug`)
+	}
+
+	// info: 第1、2条及之后每3条一条(第5、8条)；debug: 只有第1条
+	require.Equal(t, 4, recorded.FilterMessage(`info`).Len())
+	require.Equal(t, 1, recorded.FilterMessage(`debug`).Len())
+
+	t.Run("无效级别", func(t *testing.T) {
+		require.Error(t, (&SamplingConfig{Levels: map[string]SamplingLevelConfig{`verbose`: {}}}).tidy())
+	})
+}
+
+// TestConfig_RateLimit 测试按消息限流及汇总
+func TestConfig_RateLimit(t *testing.T) {
+	cfg, err := NewConfigFromYamlData(strings.NewReader(`
+service: test
+hideConsole: true
+rateLimit:
+  interval: 60000
+  burst: 3
+`))
+	require.NoError(t, err)
+
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	mongo := root.Derive(`mongo`)
+
+	for i := 0; i < 10; i++ {
+		mongo.Info(`执行成功`)
+		root.Info(`其他`)
+	}
+
+	require.Equal(t, 3, recorded.FilterMessage(`执行成功`).Len())
+	require.Equal(t, 3, recorded.FilterMessage(`其他`).Len())
+
+	// 关闭时输出最后一个周期的汇总
+	require.NoError(t, root.Close())
+
+	summaries := recorded.FilterMessage(`已抑制相似日志`).All()
+	require.Len(t, summaries, 2)
+
+	for _, summary := range summaries {
+		require.EqualValues(t, 7, summary.ContextMap()[`次数`])
+		require.Equal(t, `test`, summary.ContextMap()[`系统`], `汇总带有服务名称`)
+	}
+
+	require.Equal(t, `mongo`, recorded.FilterMessage(`已抑制相似日志`).FilterField(zap.String(`消息`, `执行成功`)).All()[0].LoggerName)
+}
+
+// TestConfig_RateLimitFieldNames 测试汇总使用配置的字段名
+func TestConfig_RateLimitFieldNames(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := (&Config{
+		Service:     "test",
+		HideConsole: true,
+		FieldPreset: FieldPresetEn,
+		FieldNames:  map[string]string{FieldCount: `suppressed`},
+		RateLimit:   &RateLimitConfig{Interval: 60000, Burst: 1},
+	}).Build(core)
+	require.NoError(t, err)
+
+	root.Info(`重复`)
+	root.Info(`重复`)
+	require.NoError(t, root.Close())
+
+	summaries := recorded.FilterMessage(`已抑制相似日志`).All()
+	require.Len(t, summaries, 1)
+	require.Equal(t, map[string]interface{}{`service`: `test`, `suppressed_message`: `重复`, `suppressed`: int64(1)}, summaries[0].ContextMap())
+}
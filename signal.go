@@ -0,0 +1,91 @@
+package log2
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	// raiseSignal 关闭日志器后重新发送信号，恢复默认的退出行为
+	raiseSignal = func(sig os.Signal) {
+		process, err := os.FindProcess(os.Getpid())
+		if err == nil {
+			err = process.Signal(sig)
+		}
+
+		if err != nil {
+			os.Exit(1)
+		}
+	}
+)
+
+// SignalOptions CloseOnSignalWith的选项
+type SignalOptions struct {
+	Signals []os.Signal // 监听的信号，为空时监听SIGTERM、SIGINT
+	Raise   bool        // 关闭后取消监听并重新发送该信号，进程按信号的默认行为退出，只适合没有自行处理该信号的程序
+}
+
+/*
+CloseOnSignal 收到信号时关闭日志器，写入所有缓冲的日志，不重新发送信号，程序需要自行处理该信号并退出
+关闭后异步、OTLP等输出不再可用，退出流程中还需要输出日志的程序应在退出流程的最后调用Close，而不是使用本函数
+参数:
+*	logger 	Logger     	日志器
+*	signals	...os.Signal	监听的信号，为空时监听SIGTERM、SIGINT
+返回值:
+*	stop   	func()      	取消监听
+*/
+func CloseOnSignal(logger Logger, signals ...os.Signal) (stop func()) {
+	return CloseOnSignalWith(logger, &SignalOptions{Signals: signals})
+}
+
+/*
+CloseOnSignalWith 按选项在收到信号时关闭日志器，Raise为true时关闭后重新发送信号
+参数:
+*	logger 	Logger        	日志器
+*	opts   	*SignalOptions	选项，为空时与CloseOnSignal相同
+返回值:
+*	stop   	func()        	取消监听
+*/
+func CloseOnSignalWith(logger Logger, opts *SignalOptions) (stop func()) {
+	if opts == nil {
+		opts = &SignalOptions{}
+	}
+
+	signals := opts.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+
+	var (
+		received = make(chan os.Signal, 1)
+		done     = make(chan struct{})
+		once     sync.Once
+	)
+
+	signal.Notify(received, signals...)
+
+	stop = func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(done)
+		})
+	}
+
+	go func() {
+		select {
+		case <-done:
+		case sig := <-received:
+			stop()
+
+			_ = logger.Close()
+
+			if opts.Raise {
+				raiseSignal(sig)
+			}
+		}
+	}()
+
+	return stop
+}
@@ -0,0 +1,118 @@
+//go:build !windows
+
+package log2
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// TestLogger_SyncAndClose 测试同步及关闭
+func TestLogger_SyncAndClose(t *testing.T) {
+	hook := &bufferHook{minLevel: zapcore.DebugLevel}
+	dir := t.TempDir()
+
+	root, err := (&Config{
+		Service:     "test",
+		HideConsole: true,
+		FilePath:    filepath.Join(dir, `app`),
+		LevelToPath: map[string]string{`error`: filepath.Join(dir, `error.log`)},
+		Hooks:       []Hook{hook},
+		Async:       &AsyncConfig{FlushInterval: 60000},
+	}).Build()
+	require.NoError(t, err)
+
+	derived := root.Derive(`close`)
+	derived.Info(`info`)
+	derived.Error(`error`)
+
+	require.NoError(t, derived.Sync())
+	require.Contains(t, hook.String(), `error`)
+
+	data, err := os.ReadFile(filepath.Join(dir, `error.log`))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `error`)
+
+	derived.Info(`before close`)
+	require.NoError(t, root.Close())
+	require.NoError(t, root.Close())
+
+	data, err = os.ReadFile(filepath.Join(dir, `app.log`))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `before close`)
+
+	t.Run("未通过Build构建", func(t *testing.T) {
+		require.NoError(t, nopLogger.Sync())
+		require.NoError(t, nopLogger.Close())
+		require.NoError(t, (&logger{}).Close())
+	})
+}
+
+// TestCloseOnSignal 测试收到信号时关闭日志器
+func TestCloseOnSignal(t *testing.T) {
+	hook := &bufferHook{minLevel: zapcore.DebugLevel}
+	root, err := (&Config{Service: "test", HideConsole: true, Hooks: []Hook{hook}, Async: &AsyncConfig{FlushInterval: 60000}}).Build()
+	require.NoError(t, err)
+
+	raised := make(chan os.Signal, 1)
+	original := raiseSignal
+	raiseSignal = func(sig os.Signal) { raised <- sig }
+
+	defer func() {
+		raiseSignal = original
+	}()
+
+	stop := CloseOnSignalWith(root, &SignalOptions{Signals: []os.Signal{syscall.SIGUSR1}, Raise: true})
+	defer stop()
+
+	root.Info(`退出前`)
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+	select {
+	case sig := <-raised:
+		require.Equal(t, syscall.SIGUSR1, sig)
+	case <-time.After(5 * time.Second):
+		t.Fatal(`未收到信号`)
+	}
+
+	require.Contains(t, hook.String(), `退出前`)
+
+	t.Run("默认不重新发送信号", func(t *testing.T) {
+		// 程序自行监听该信号
+		handled := make(chan os.Signal, 1)
+		signal.Notify(handled, syscall.SIGUSR2)
+		defer signal.Stop(handled)
+
+		stop := CloseOnSignal(root, syscall.SIGUSR2)
+		defer stop()
+
+		root.Info(`程序自行处理`)
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			t.Fatal(`未收到信号`)
+		}
+
+		require.Eventually(t, func() bool {
+			return strings.Contains(hook.String(), `程序自行处理`)
+		}, 5*time.Second, 5*time.Millisecond)
+
+		select {
+		case sig := <-raised:
+			t.Fatalf(`重新发送了信号%s`, sig)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		require.Len(t, handled, 0, `程序只收到一次信号`)
+	})
+}
@@ -0,0 +1,305 @@
+package log2
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sort"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// slogHandler 使用日志器输出的slog.Handler
+type slogHandler struct {
+	core zapcore.Core
+	name string
+}
+
+/*
+NewSlogHandler 生成使用日志器输出的slog.Handler，与日志器共享Config.Build构建的所有输出
+级别映射: 低于Info为Debug，低于Warn为Info，低于Error为Warn，其余为Error
+参数:
+*	target      	Logger      	日志器
+返回值:
+*	slog.Handler	slog.Handler	slog处理器
+*/
+func NewSlogHandler(target Logger) slog.Handler {
+	handler := &slogHandler{}
+
+	if l, ok := target.(*logger); ok && l.underlying != nil {
+		handler.core = l.underlying.Core()
+		handler.name = l.underlying.Name()
+	} else {
+		handler.core = loggerCore{Logger: target}
+	}
+
+	return handler
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.core.Enabled(zapLevelOf(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	entry := zapcore.Entry{
+		Level:      zapLevelOf(record.Level),
+		Time:       record.Time,
+		LoggerName: h.name,
+		Message:    record.Message,
+	}
+
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		entry.Caller = zapcore.EntryCaller{Defined: true, PC: record.PC, File: frame.File, Line: frame.Line, Function: frame.Function}
+	}
+
+	checked := h.core.Check(entry, nil)
+	if checked == nil {
+		return nil
+	}
+
+	fields := FieldsFromContext(ctx)
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttrField(fields, attr)
+
+		return true
+	})
+
+	checked.Write(fields...)
+
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []zapcore.Field
+	for _, attr := range attrs {
+		fields = appendAttrField(fields, attr)
+	}
+
+	return &slogHandler{core: h.core.With(fields), name: h.name}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == `` {
+		return h
+	}
+
+	return &slogHandler{core: h.core.With([]zapcore.Field{zap.Namespace(name)}), name: h.name}
+}
+
+// zapLevelOf 将slog级别映射为zap级别
+func zapLevelOf(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// slogLevelOf 将zap级别映射为slog级别，Error以上的级别在Error基础上递增
+func slogLevelOf(level zapcore.Level) slog.Level {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return slog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError + slog.Level(level-zapcore.ErrorLevel)
+	}
+}
+
+// appendAttrField 将slog属性转换为字段，遵循slog忽略空属性、空名称分组内联的约定
+func appendAttrField(fields []zapcore.Field, attr slog.Attr) []zapcore.Field {
+	attr.Value = attr.Value.Resolve()
+
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(attr.Key, attr.Value.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(attr.Key, attr.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(attr.Key, attr.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(attr.Key, attr.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(attr.Key, attr.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(attr.Key, attr.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(attr.Key, attr.Value.Time()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		if len(group) == 0 {
+			return fields
+		}
+
+		if attr.Key == `` {
+			for _, item := range group {
+				fields = appendAttrField(fields, item)
+			}
+
+			return fields
+		}
+
+		return append(fields, zap.Object(attr.Key, zapcore.ObjectMarshalerFunc(func(encoder zapcore.ObjectEncoder) error {
+			for _, field := range appendAttrFields(group) {
+				field.AddTo(encoder)
+			}
+
+			return nil
+		})))
+	default:
+		if err, ok := attr.Value.Any().(error); ok {
+			return append(fields, zap.NamedError(attr.Key, err))
+		}
+
+		return append(fields, zap.Any(attr.Key, attr.Value.Any()))
+	}
+}
+
+func appendAttrFields(attrs []slog.Attr) (fields []zapcore.Field) {
+	for _, attr := range attrs {
+		fields = appendAttrField(fields, attr)
+	}
+
+	return fields
+}
+
+// loggerCore 通过Logger接口输出的core，用于非本包实现的日志器，无法保留调用位置
+type loggerCore struct {
+	Logger
+}
+
+func (c loggerCore) Enabled(level zapcore.Level) bool {
+	return level >= c.Logger.Level()
+}
+
+func (c loggerCore) With(fields []zapcore.Field) zapcore.Core {
+	return loggerCore{Logger: c.Logger.With(fields...)}
+}
+
+func (c loggerCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c loggerCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	switch entry.Level {
+	case zapcore.DebugLevel:
+		c.Logger.Debug(entry.Message, fields...)
+	case zapcore.InfoLevel:
+		c.Logger.Info(entry.Message, fields...)
+	case zapcore.WarnLevel:
+		c.Logger.Warn(entry.Message, fields...)
+	default:
+		c.Logger.Error(entry.Message, fields...)
+	}
+
+	return nil
+}
+
+func (c loggerCore) Sync() error {
+	return c.Logger.Sync()
+}
+
+// slogCore 输出到slog.Handler的core
+type slogCore struct {
+	handler slog.Handler
+}
+
+func (c slogCore) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), slogLevelOf(level))
+}
+
+func (c slogCore) With(fields []zapcore.Field) zapcore.Core {
+	return slogCore{handler: c.handler.WithAttrs(slogAttrsOf(fields))}
+}
+
+func (c slogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c slogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	record := slog.NewRecord(entry.Time, slogLevelOf(entry.Level), entry.Message, entry.Caller.PC)
+
+	if entry.LoggerName != `` {
+		record.AddAttrs(slog.String(`logger`, entry.LoggerName))
+	}
+
+	record.AddAttrs(slogAttrsOf(fields)...)
+
+	return c.handler.Handle(context.Background(), record)
+}
+
+func (c slogCore) Sync() error {
+	return nil
+}
+
+// slogAttrsOf 将字段转换为slog属性，嵌套的对象转换为分组
+func slogAttrsOf(fields []zapcore.Field) []slog.Attr {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	return slogAttrsOfMap(encoder.Fields)
+}
+
+func slogAttrsOfMap(values map[string]interface{}) []slog.Attr {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(values))
+
+	for _, key := range keys {
+		value := values[key]
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			attrs = append(attrs, slog.Attr{Key: key, Value: slog.GroupValue(slogAttrsOfMap(nested)...)})
+
+			continue
+		}
+
+		attrs = append(attrs, slog.Any(key, value))
+	}
+
+	return attrs
+}
+
+/*
+FromSlog 生成输出到slog日志器的日志器，调用位置通过slog.Record.PC传递
+参数:
+*	target	*slog.Logger	slog日志器，为nil时使用slog.Default()
+返回值:
+*	Logger	Logger      	日志器
+*/
+func FromSlog(target *slog.Logger) Logger {
+	if target == nil {
+		target = slog.Default()
+	}
+
+	return NewLogger(zap.New(slogCore{handler: target.Handler()}, zap.AddCaller()), ``, 1, false, false, nil, nil)
+}
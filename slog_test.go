@@ -0,0 +1,101 @@
+package log2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestNewSlogHandler 测试slog通过日志器输出
+func TestNewSlogHandler(t *testing.T) {
+	cfg := &Config{Service: "test", Level: zapcore.DebugLevel, HideConsole: true}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	derived := root.Derive(`slog`)
+	sl := slog.New(NewSlogHandler(derived))
+
+	sl.With(`a`, 1).WithGroup(`g`).Info(`分组`, `b`, `x`, slog.Group(`s`, `c`, true), slog.Group(``, `d`, 2))
+
+	require.Equal(t, 1, recorded.Len())
+	entry := recorded.All()[0]
+	require.Equal(t, `分组`, entry.Message)
+	require.Equal(t, `slog`, entry.LoggerName)
+	require.Equal(t, zapcore.InfoLevel, entry.Level)
+	require.Equal(t, `slog_test.go`, filepath.Base(entry.Caller.File))
+	require.Equal(t, `test`, entry.ContextMap()[`系统`])
+	require.EqualValues(t, 1, entry.ContextMap()[`a`])
+	require.Equal(t, map[string]interface{}{`b`: `x`, `s`: map[string]interface{}{`c`: true}, `d`: int64(2)}, entry.ContextMap()[`g`])
+
+	t.Run("级别映射", func(t *testing.T) {
+		recorded.TakeAll()
+
+		derived.SetLevel(zapcore.WarnLevel)
+		sl.Info(`不可见`)
+		sl.Log(nil, slog.LevelWarn+1, `警告`)
+		sl.Error(`错误`, `err`, errors.New(`x`))
+
+		logs := recorded.TakeAll()
+		require.Len(t, logs, 2)
+		require.Equal(t, zapcore.WarnLevel, logs[0].Level)
+		require.Equal(t, zapcore.ErrorLevel, logs[1].Level)
+		require.Equal(t, `x`, logs[1].ContextMap()[`err`])
+
+		// 根日志器不受影响
+		slog.New(NewSlogHandler(root)).Debug(`可见`)
+		require.Equal(t, 1, recorded.Len())
+	})
+
+	t.Run("非本包日志器", func(t *testing.T) {
+		recorded.TakeAll()
+
+		sl := slog.New(NewSlogHandler(wrappedLogger{Logger: FromSlog(sl)}))
+		sl.Info(`不可见`)
+		sl.Warn(`包装`, `a`, `x`)
+
+		logs := recorded.TakeAll()
+		require.Len(t, logs, 1)
+		require.Equal(t, `包装`, logs[0].Message)
+		require.Equal(t, `x`, logs[0].ContextMap()[`a`])
+	})
+}
+
+// wrappedLogger 模拟其他实现的日志器
+type wrappedLogger struct {
+	Logger
+}
+
+// TestFromSlog 测试日志器输出到slog
+func TestFromSlog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	target := FromSlog(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelInfo})))
+
+	target.Debug(`不可见`)
+	target.Derive(`sub`).With(zap.String(`a`, `x`), zap.Namespace(`n`), zap.Int(`b`, 1)).Warn(`警告`)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, `WARN`, record[`level`])
+	require.Equal(t, `警告`, record[`msg`])
+	require.Equal(t, `sub`, record[`logger`])
+	require.Equal(t, `x`, record[`a`])
+	require.Equal(t, map[string]interface{}{`b`: float64(1)}, record[`n`])
+	require.Equal(t, `slog_test.go`, filepath.Base(record[`source`].(map[string]interface{})[`file`].(string)))
+
+	t.Run("经过NewSlogHandler再转回", func(t *testing.T) {
+		buf.Reset()
+		slog.New(NewSlogHandler(target)).Error(`错误`)
+		require.Contains(t, buf.String(), `"level":"ERROR"`)
+		require.Contains(t, buf.String(), `slog_test.go`)
+	})
+}
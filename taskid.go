@@ -0,0 +1,165 @@
+package log2
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
+)
+
+// IDGenerator 任务ID生成方式
+type IDGenerator string
+
+const (
+	// IDObjectID mongo的ObjectID，24位十六进制
+	IDObjectID = IDGenerator(`objectid`)
+	// IDUUID 随机的UUID v4
+	IDUUID = IDGenerator(`uuid`)
+	// IDUUIDv7 按时间排序的UUID v7
+	IDUUIDv7 = IDGenerator(`uuidv7`)
+	// IDULID 按时间排序的ULID
+	IDULID = IDGenerator(`ulid`)
+	// IDSnowflake 雪花算法，41位毫秒时间戳+10位节点+12位序号
+	IDSnowflake = IDGenerator(`snowflake`)
+)
+
+const (
+	// snowflakeEpoch 雪花算法的起始时间 2020-01-01T00:00:00Z
+	snowflakeEpoch   = 1577836800000
+	snowflakeMaxNode = 1<<10 - 1
+)
+
+// TaskIDConfig Start生成任务ID的配置
+type TaskIDConfig struct {
+	Generator IDGenerator   `yaml:"generator"` // 生成方式,objectid/uuid/uuidv7/ulid/snowflake，默认objectid
+	Key       string        `yaml:"key"`       // 字段名，默认为Config.FieldNames中taskID的名称
+	Node      int64         `yaml:"node"`      // 雪花算法的节点号，0-1023
+	New       func() string `yaml:"-"`         // 自定义生成函数，设置后忽略Generator
+	newID     func() string
+}
+
+func (c *TaskIDConfig) tidy(defaultKey string) error {
+	if c.Key == `` {
+		c.Key = defaultKey
+	}
+
+	if c.New != nil {
+		c.newID = c.New
+
+		return nil
+	}
+
+	switch c.Generator {
+	case ``, IDObjectID:
+		c.Generator = IDObjectID
+		c.newID = newObjectID
+	case IDUUID:
+		c.newID = uuid.NewString
+	case IDUUIDv7:
+		c.newID = func() string {
+			return uuid.Must(uuid.NewV7()).String()
+		}
+	case IDULID:
+		c.newID = func() string {
+			return ulid.Make().String()
+		}
+	case IDSnowflake:
+		if c.Node < 0 || c.Node > snowflakeMaxNode {
+			return errors.Errorf(`雪花算法节点号[%d]超出范围0-%d`, c.Node, snowflakeMaxNode)
+		}
+
+		c.newID = (&snowflake{node: c.Node}).next
+	default:
+		return errors.Errorf(`未知的任务ID生成方式[%s]`, c.Generator)
+	}
+
+	return nil
+}
+
+// defaultTaskID 未配置时使用的任务ID生成方式
+var defaultTaskID = &TaskIDConfig{Key: zhFieldNames[FieldTaskID], Generator: IDObjectID, newID: newObjectID}
+
+// newTaskID 按日志器所属实例的任务ID配置生成新的ID
+func newTaskID(target Logger) string {
+	if result := instanceOf(target); result != nil {
+		if out := result.output.Load(); out != nil && out.taskID != nil {
+			return out.taskID.newID()
+		}
+	}
+
+	return defaultTaskID.newID()
+}
+
+var (
+	objectIDCounter = randomUint32()
+	objectIDProcess = randomProcessUnique()
+)
+
+// newObjectID 生成与mongo ObjectID相同格式的ID: 4字节秒级时间戳+5字节进程随机值+3字节计数
+func newObjectID() string {
+	var id [12]byte
+
+	binary.BigEndian.PutUint32(id[0:4], uint32(time.Now().Unix()))
+	copy(id[4:9], objectIDProcess[:])
+
+	counter := atomic.AddUint32(&objectIDCounter, 1)
+	id[9], id[10], id[11] = byte(counter>>16), byte(counter>>8), byte(counter)
+
+	return hex.EncodeToString(id[:])
+}
+
+func randomUint32() uint32 {
+	var data [4]byte
+
+	_, _ = rand.Read(data[:])
+
+	return binary.BigEndian.Uint32(data[:])
+}
+
+func randomProcessUnique() (result [5]byte) {
+	_, _ = rand.Read(result[:])
+
+	return result
+}
+
+// snowflake 雪花算法生成器，同一毫秒内序号用完时等待下一毫秒
+type snowflake struct {
+	lock     sync.Mutex
+	node     int64
+	last     int64
+	sequence int64
+}
+
+func (s *snowflake) next() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now().UnixMilli()
+	if now < s.last {
+		// 时钟回拨时沿用上次的时间
+		now = s.last
+	}
+
+	if now == s.last {
+		s.sequence = (s.sequence + 1) & 0xfff
+		if s.sequence == 0 {
+			for now <= s.last {
+				time.Sleep(time.Millisecond / 10)
+				now = time.Now().UnixMilli()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+
+	s.last = now
+
+	return strconv.FormatInt((now-snowflakeEpoch)<<22|s.node<<12|s.sequence, 10)
+}
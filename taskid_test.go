@@ -0,0 +1,98 @@
+package log2
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestTaskIDConfig 测试各种任务ID生成方式
+func TestTaskIDConfig(t *testing.T) {
+	tests := []struct {
+		generator IDGenerator
+		pattern   string
+	}{
+		{IDObjectID, `^[0-9a-f]{24}$`},
+		{IDUUID, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$`},
+		{IDUUIDv7, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$`},
+		{IDULID, `^[0-9A-HJKMNP-TV-Z]{26}$`},
+		{IDSnowflake, `^[0-9]+$`},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.generator), func(t *testing.T) {
+			config := &TaskIDConfig{Generator: test.generator, Node: 1}
+			require.NoError(t, config.tidy(zhFieldNames[FieldTaskID]))
+
+			ids := map[string]bool{}
+			for i := 0; i < 5000; i++ {
+				id := config.newID()
+				require.Regexp(t, regexp.MustCompile(test.pattern), id)
+				ids[id] = true
+			}
+
+			require.Len(t, ids, 5000)
+		})
+	}
+
+	t.Run("ObjectID兼容mongo", func(t *testing.T) {
+		id, err := primitive.ObjectIDFromHex(newObjectID())
+		require.NoError(t, err)
+		require.False(t, id.IsZero())
+	})
+
+	t.Run("雪花算法递增", func(t *testing.T) {
+		generator := &snowflake{node: snowflakeMaxNode}
+		last := int64(0)
+
+		for i := 0; i < 10000; i++ {
+			id, err := strconv.ParseInt(generator.next(), 10, 64)
+			require.NoError(t, err)
+			require.Greater(t, id, last)
+			require.EqualValues(t, snowflakeMaxNode, id>>12&snowflakeMaxNode)
+			last = id
+		}
+	})
+
+	t.Run("非法配置", func(t *testing.T) {
+		require.Error(t, (&TaskIDConfig{Generator: `x`}).tidy(``))
+		require.Error(t, (&TaskIDConfig{Generator: IDSnowflake, Node: 1024}).tidy(``))
+	})
+}
+
+// TestLogger_StartWithID 测试通过配置指定任务ID的生成方式及字段名
+func TestLogger_StartWithID(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	cfg := &Config{Service: "test", HideConsole: true, TaskID: &TaskIDConfig{Key: `trace`, New: func() string {
+		return `custom`
+	}}}
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	root.Derive(`sub`).Start().Info(`生成`)
+	root.StartWithID(`upstream`).Info(`沿用`)
+	root.StartWithID(``).Info(`为空时生成`)
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 3)
+	require.Equal(t, `custom`, logs[0].ContextMap()[`trace`])
+	require.Equal(t, `upstream`, logs[1].ContextMap()[`trace`])
+	require.Equal(t, `custom`, logs[2].ContextMap()[`trace`])
+
+	t.Run("默认配置", func(t *testing.T) {
+		root, err := (&Config{Service: "test", HideConsole: true}).Build(core)
+		require.NoError(t, err)
+
+		root.Start().Info(`默认`)
+		require.Regexp(t, `^[0-9a-f]{24}$`, recorded.TakeAll()[0].ContextMap()[`任务ID`])
+
+		_, err = (&Config{Service: "test", TaskID: &TaskIDConfig{Generator: `x`}}).Build()
+		require.Error(t, err)
+	})
+}
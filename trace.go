@@ -0,0 +1,131 @@
+package log2
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// TraceConfig OpenTelemetry链路关联配置，开启后WithContext及XxxContext会从context中的span添加字段
+type TraceConfig struct {
+	RecordErrors bool `yaml:"recordErrors"` // 是否将Error及以上级别的日志记录为span事件
+}
+
+// withTrace 添加context中span的链路ID、span ID及链路标志字段，字段名按FieldNames
+func (l *logger) withTrace(ctx context.Context) *logger {
+	if l.instance == nil || l.underlying == nil {
+		return l
+	}
+
+	config := l.instance.output.Load().trace
+	if config == nil {
+		return l
+	}
+
+	names := fieldNamesOf(l)
+	span := trace.SpanFromContext(ctx)
+	spanContext := span.SpanContext()
+
+	// 已经携带链路字段时不重复添加，如合并了context中日志器的字段
+	if !spanContext.IsValid() || l.hasField(names[FieldTraceID]) {
+		return l
+	}
+
+	result := l.with(
+		zap.String(names[FieldTraceID], spanContext.TraceID().String()),
+		zap.String(names[FieldSpanID], spanContext.SpanID().String()),
+		zap.String(names[FieldTraceFlag], spanContext.TraceFlags().String()),
+	)
+
+	if config.RecordErrors && span.IsRecording() {
+		result.underlying = result.underlying.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			// 放在级别过滤之内，被过滤的日志不记录为span事件
+			if level, ok := core.(levelCore); ok {
+				return levelCore{Core: spanEventCore{Core: level.Core, span: span}, levels: level.levels}
+			}
+
+			return spanEventCore{Core: core, span: span}
+		}))
+	}
+
+	return result
+}
+
+// spanEventCore 将Error及以上级别的日志同时记录为span事件
+type spanEventCore struct {
+	zapcore.Core
+	span trace.Span
+}
+
+func (c spanEventCore) With(fields []zapcore.Field) zapcore.Core {
+	return spanEventCore{Core: c.Core.With(fields), span: c.span}
+}
+
+func (c spanEventCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level >= zapcore.ErrorLevel {
+		checked = checked.AddCore(entry, spanRecorder{span: c.span})
+	}
+
+	return c.Core.Check(entry, checked)
+}
+
+// spanRecorder 只记录span事件的core
+type spanRecorder struct {
+	span trace.Span
+}
+
+func (r spanRecorder) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.ErrorLevel
+}
+
+func (r spanRecorder) With([]zapcore.Field) zapcore.Core {
+	return r
+}
+
+func (r spanRecorder) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if r.Enabled(entry.Level) {
+		return checked.AddCore(entry, r)
+	}
+
+	return checked
+}
+
+func (r spanRecorder) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	keys := make([]string, 0, len(encoder.Fields))
+	for key := range encoder.Fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	attributes := []attribute.KeyValue{
+		attribute.String(`log.severity`, entry.Level.CapitalString()),
+		attribute.String(`log.message`, entry.Message),
+	}
+
+	if entry.LoggerName != `` {
+		attributes = append(attributes, attribute.String(`log.logger`, entry.LoggerName))
+	}
+
+	for _, key := range keys {
+		attributes = append(attributes, attribute.String(key, fmt.Sprint(encoder.Fields[key])))
+	}
+
+	r.span.AddEvent(`log`, trace.WithTimestamp(entry.Time), trace.WithAttributes(attributes...))
+
+	return nil
+}
+
+func (r spanRecorder) Sync() error {
+	return nil
+}
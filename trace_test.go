@@ -0,0 +1,87 @@
+package log2
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestConfig_Trace 测试从context中的span添加链路字段并记录错误事件
+func TestConfig_Trace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	cfg := &Config{Service: "test", Level: zapcore.DebugLevel, HideConsole: true, Trace: &TraceConfig{RecordErrors: true}}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	ctx, span := provider.Tracer(`test`).Start(context.Background(), `span`)
+	spanContext := span.SpanContext()
+
+	root.InfoContext(ctx, `信息`)
+	root.Derive(`sub`).WithContext(ctx).Error(`错误`, zap.Error(errors.New(`x`)), zap.Int(`a`, 1))
+	root.InfoContext(context.Background(), `无span`)
+	muted := root.Derive(`muted`).SetLevel(zapcore.FatalLevel)
+	muted.WithContext(ctx).Error(`被过滤的错误不记录为span事件`)
+	span.End()
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 3)
+
+	for _, entry := range logs[:2] {
+		require.Equal(t, spanContext.TraceID().String(), entry.ContextMap()[`trace_id`])
+		require.Equal(t, spanContext.SpanID().String(), entry.ContextMap()[`span_id`])
+		require.Equal(t, `01`, entry.ContextMap()[`trace_flags`])
+	}
+
+	require.NotContains(t, logs[2].ContextMap(), `trace_id`)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Len(t, spans[0].Events, 1)
+
+	attributes := map[string]string{}
+	for _, attribute := range spans[0].Events[0].Attributes {
+		attributes[string(attribute.Key)] = attribute.Value.AsString()
+	}
+
+	require.Equal(t, map[string]string{
+		`log.severity`: `ERROR`,
+		`log.message`:  `错误`,
+		`log.logger`:   `sub`,
+		`error`:        `x`,
+		`a`:            `1`,
+	}, attributes)
+
+	t.Run("未配置时不关联", func(t *testing.T) {
+		root, err := (&Config{Service: "test", HideConsole: true}).Build(core)
+		require.NoError(t, err)
+
+		root.InfoContext(ctx, `信息`)
+		require.NotContains(t, recorded.TakeAll()[0].ContextMap(), `trace_id`)
+	})
+
+	t.Run("按FieldNames命名", func(t *testing.T) {
+		root, err := (&Config{Service: "test", HideConsole: true, FieldPreset: FieldPresetECS, Trace: &TraceConfig{}}).Build(core)
+		require.NoError(t, err)
+
+		root.InfoContext(ctx, `信息`)
+		root.WithContext(ctx).WithContext(ctx).Info(`不重复添加`)
+
+		for _, entry := range recorded.TakeAll() {
+			require.Equal(t, spanContext.TraceID().String(), entry.ContextMap()[`trace.id`])
+			require.Equal(t, spanContext.SpanID().String(), entry.ContextMap()[`span.id`])
+			require.NotContains(t, entry.ContextMap(), `trace_id`)
+			require.Len(t, entry.Context, 4)
+		}
+	})
+}
@@ -0,0 +1,642 @@
+package log2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec // S3要求的Content-MD5
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultUploadRegion        = `us-east-1`
+	defaultUploadQueueSize     = 1024
+	defaultUploadTimeout       = 60000
+	defaultUploadMaxRetries    = 3
+	defaultUploadRetryInterval = 1000
+	defaultUploadCloseTimeout  = 5000
+	// defaultUploadManifest 默认的上传清单文件名，位于日志目录下
+	defaultUploadManifest = `upload-manifest.jsonl`
+	// minManifestCompact 清单行数不少于此值且超过有效记录的两倍时重写清单
+	minManifestCompact = 64
+	// uploadName 上传时输出的日志器名称
+	uploadName = `upload`
+	// amzDateLayout 签名使用的时间格式
+	amzDateLayout = `20060102T150405Z`
+)
+
+// UploadConfig 切分出的文件上传到S3兼容的对象存储的配置，使用path-style访问
+type UploadConfig struct {
+	Endpoint      string `yaml:"endpoint"`      // 地址，如http://127.0.0.1:9000
+	Bucket        string `yaml:"bucket"`        // 存储桶
+	Prefix        string `yaml:"prefix"`        // 对象名前缀，对象名为Prefix+文件名
+	Region        string `yaml:"region"`        // 区域，默认us-east-1
+	AccessKey     string `yaml:"accessKey"`     // 为空时不签名
+	SecretKey     string `yaml:"secretKey"`     // 密钥
+	Manifest      string `yaml:"manifest"`      // 已上传文件的清单，每行一个JSON，默认为日志目录下的upload-manifest.jsonl，本地已不存在的文件的记录会被定期去掉
+	KeepLocal     bool   `yaml:"keepLocal"`     // 上传成功后保留本地文件，默认删除
+	QueueSize     int    `yaml:"queueSize"`     // 等待上传的文件数，队列满时跳过，下次启动时补传，默认1024
+	Timeout       int    `yaml:"timeout"`       // 单次上传超时,单位为毫秒，默认60000
+	MaxRetries    int    `yaml:"maxRetries"`    // 最大重试次数，默认3，小于0时不重试
+	RetryInterval int    `yaml:"retryInterval"` // 首次重试间隔,单位为毫秒，之后每次翻倍，默认1000
+	CloseTimeout  int    `yaml:"closeTimeout"`  // 关闭时等待队列上传完成的时间,单位为毫秒，默认5000，超时后中断，剩余的文件重新加载后或者下次启动时上传
+}
+
+func (c *UploadConfig) tidy() error {
+	if c.Endpoint == `` {
+		return errors.New(`上传地址为空`)
+	}
+
+	if _, err := url.Parse(c.Endpoint); err != nil {
+		return errors.Wrapf(err, `解析上传地址[%s]`, c.Endpoint)
+	}
+
+	if c.Bucket == `` {
+		return errors.New(`存储桶为空`)
+	}
+
+	if c.Region == `` {
+		c.Region = defaultUploadRegion
+	}
+
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultUploadQueueSize
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = defaultUploadTimeout
+	}
+
+	if c.MaxRetries == 0 {
+		c.MaxRetries = defaultUploadMaxRetries
+	}
+
+	if c.RetryInterval <= 0 {
+		c.RetryInterval = defaultUploadRetryInterval
+	}
+
+	if c.CloseTimeout <= 0 {
+		c.CloseTimeout = defaultUploadCloseTimeout
+	}
+
+	return nil
+}
+
+// UploadRecord 上传清单中的一条记录
+type UploadRecord struct {
+	File       string    `json:"file"`       // 本地文件路径
+	Key        string    `json:"key"`        // 对象名
+	Size       int64     `json:"size"`       // 字节数
+	SHA256     string    `json:"sha256"`     // 内容的SHA256
+	ETag       string    `json:"etag"`       // 对象存储返回的ETag
+	UploadedAt time.Time `json:"uploadedAt"` // 上传完成的时间
+}
+
+// uploader 在后台逐个上传切分出的文件，成功后记录清单并删除本地文件
+type uploader struct {
+	config   *UploadConfig
+	manifest string
+	client   *http.Client
+	logger   *zap.Logger
+	queue    chan string
+	lock     sync.Mutex // 保护closed、pending及uploaded
+	closed   bool
+	started  bool
+	pending  map[string]bool // 已在队列中的文件，关闭后为未上传的文件
+	uploaded map[string]bool // 清单中已上传且本地依然存在的文件
+	lines    int             // 清单的行数，只在newUploader及上传协程中使用
+	busy     *busyFiles      // 队列中的文件同时标记在此，清理时跳过
+	stopped  chan struct{}
+	ctx      context.Context // 关闭超时后取消，中断正在进行的上传
+	cancel   context.CancelFunc
+
+	fileKey, bytesKey, errorKey string // 日志使用的字段名
+}
+
+/*
+newUploader 新建上传，start后开始上传，之前加入的文件在队列中等待
+参数:
+*	config	*UploadConfig    	配置
+*	dir   	string           	日志目录，未配置清单路径时清单位于此目录
+*	names 	map[string]string	内置字段的名称
+返回值:
+*	*uploader	*uploader	上传
+*	error    	error    	错误
+*/
+func newUploader(config *UploadConfig, dir string, names map[string]string) (*uploader, error) {
+	if err := config.tidy(); err != nil {
+		return nil, errors.Wrap(err, `上传配置`)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	result := &uploader{
+		ctx:      ctx,
+		cancel:   cancel,
+		config:   config,
+		manifest: config.Manifest,
+		client:   &http.Client{},
+		logger:   zap.NewNop(),
+		queue:    make(chan string, config.QueueSize),
+		pending:  make(map[string]bool),
+		uploaded: make(map[string]bool),
+		stopped:  make(chan struct{}),
+		fileKey:  names[FieldFile],
+		bytesKey: names[FieldBytes],
+		errorKey: names[FieldError],
+	}
+
+	if result.manifest == `` {
+		result.manifest = filepath.Join(dir, defaultUploadManifest)
+	}
+
+	if err := result.loadManifest(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// loadManifest 读取清单中已上传的文件
+func (u *uploader) loadManifest() error {
+	file, err := os.Open(u.manifest)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, `打开上传清单[%s]`, u.manifest)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		u.lines++
+
+		var record UploadRecord
+		if json.Unmarshal(scanner.Bytes(), &record) == nil && record.File != `` {
+			u.uploaded[record.File] = true
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return errors.Wrapf(err, `读取上传清单[%s]`, u.manifest)
+	}
+
+	return u.compactManifest()
+}
+
+// compactManifest 去掉本地已不存在的文件，清单中失效的行过多时重写清单
+func (u *uploader) compactManifest() error {
+	removeMissing(&u.lock, u.uploaded)
+
+	u.lock.Lock()
+	kept := len(u.uploaded)
+	u.lock.Unlock()
+
+	if u.lines < minManifestCompact || u.lines <= 2*kept {
+		return nil
+	}
+
+	return u.rewriteManifest()
+}
+
+// rewriteManifest 只保留uploaded中的文件的记录，写入临时文件后替换清单
+func (u *uploader) rewriteManifest() error {
+	data, err := os.ReadFile(u.manifest)
+	if err != nil {
+		return errors.Wrapf(err, `读取上传清单[%s]`, u.manifest)
+	}
+
+	var (
+		buffer bytes.Buffer
+		lines  int
+	)
+
+	u.lock.Lock()
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var record UploadRecord
+		if json.Unmarshal(line, &record) == nil && u.uploaded[record.File] {
+			buffer.Write(append(line, '\n'))
+			lines++
+		}
+	}
+	u.lock.Unlock()
+
+	temp := u.manifest + `.tmp`
+	if err = os.WriteFile(temp, buffer.Bytes(), 0o644); err != nil {
+		return errors.Wrapf(err, `写入上传清单[%s]`, temp)
+	}
+
+	if err = os.Rename(temp, u.manifest); err != nil {
+		return errors.Wrapf(err, `替换上传清单[%s]`, u.manifest)
+	}
+
+	u.lines = lines
+
+	return nil
+}
+
+/*
+start 开始上传，并补传此前未上传的文件
+参数:
+*	logger  	*zap.Logger      	输出上传结果的日志器
+*	sources 	[]retentionSource	所有日志文件
+*	handover	map[string]bool  	重新加载配置时旧的上传未完成的文件，不为空时只补传其中属于sources的文件
+*/
+func (u *uploader) start(logger *zap.Logger, sources []retentionSource, handover map[string]bool) {
+	u.lock.Lock()
+	u.logger = logger
+	u.started = true
+	u.lock.Unlock()
+
+	for _, source := range sources {
+		files, err := source.archives()
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			if pendingArchive(source, file.path) || (handover != nil && !handover[file.path]) {
+				continue
+			}
+
+			u.enqueue(file.path)
+		}
+	}
+
+	go u.run()
+}
+
+// pendingArchive 文件是否还未完成，开启压缩时未压缩的文件及正在压缩的文件都未完成
+func pendingArchive(source retentionSource, path string) bool {
+	if !source.compress() {
+		return false
+	}
+
+	ext := compressedExtOf(path)
+	if ext == `` {
+		return true
+	}
+
+	_, err := os.Stat(strings.TrimSuffix(path, ext))
+
+	return err == nil
+}
+
+// enqueue 加入上传队列，已上传、已在队列中或者已关闭时跳过
+func (u *uploader) enqueue(path string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.closed || u.pending[path] || u.uploaded[path] {
+		return
+	}
+
+	select {
+	case u.queue <- path:
+		u.pending[path] = true
+		u.busy.add(path)
+	default:
+		u.logger.Warn(`上传队列已满，跳过`, zap.String(u.fileKey, path))
+	}
+}
+
+func (u *uploader) run() {
+	defer close(u.stopped)
+
+	for path := range u.queue {
+		// 关闭超时后剩余的文件保留在pending中
+		if u.ctx.Err() != nil {
+			continue
+		}
+
+		record, err := u.upload(path)
+		if err != nil && u.ctx.Err() != nil {
+			continue
+		}
+
+		u.lock.Lock()
+		delete(u.pending, path)
+		u.busy.done(path)
+
+		if err == nil {
+			u.uploaded[path] = true
+		}
+		u.lock.Unlock()
+
+		if err != nil {
+			u.logger.Error(`上传日志文件失败`, zap.String(u.fileKey, path), zap.NamedError(u.errorKey, err))
+
+			continue
+		}
+
+		u.logger.Info(`上传日志文件`, zap.String(u.fileKey, path), zap.Int64(u.bytesKey, record.Size))
+
+		if err = u.compactManifest(); err != nil {
+			u.logger.Error(`整理上传清单失败`, zap.NamedError(u.errorKey, err))
+		}
+	}
+}
+
+/*
+upload 按重试配置上传一个文件，成功后记录清单并按配置删除本地文件
+参数:
+*	path	string	文件路径
+返回值:
+*	*UploadRecord	*UploadRecord	清单记录
+*	error        	error        	错误
+*/
+func (u *uploader) upload(path string) (*UploadRecord, error) {
+	var (
+		record *UploadRecord
+		err    error
+	)
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(u.ctx, time.Duration(u.config.Timeout)*time.Millisecond)
+		record, err = u.put(ctx, path)
+		cancel()
+
+		if err == nil || attempt >= u.config.MaxRetries || !uploadRetryable(err) || !u.wait(attempt) {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.appendManifest(record); err != nil {
+		return nil, err
+	}
+
+	if !u.config.KeepLocal {
+		if err = os.Remove(path); err != nil {
+			return nil, errors.Wrapf(err, `删除已上传的日志文件[%s]`, path)
+		}
+	}
+
+	return record, nil
+}
+
+// wait 等待第attempt次重试的间隔，关闭超时时返回false
+func (u *uploader) wait(attempt int) bool {
+	timer := time.NewTimer(time.Duration(float64(u.config.RetryInterval)*math.Pow(2, float64(attempt))) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-u.ctx.Done():
+		return false
+	}
+}
+
+// appendManifest 追加一条清单记录
+func (u *uploader) appendManifest(record *UploadRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, `编码上传清单`)
+	}
+
+	file, err := os.OpenFile(u.manifest, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, `打开上传清单[%s]`, u.manifest)
+	}
+
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+
+		return errors.Wrapf(err, `写入上传清单[%s]`, u.manifest)
+	}
+
+	u.lines++
+
+	return errors.Wrapf(file.Close(), `关闭上传清单[%s]`, u.manifest)
+}
+
+// uploadError 上传错误，retryable表示是否可以重试
+type uploadError struct {
+	err       error
+	retryable bool
+}
+
+func (e *uploadError) Error() string {
+	return e.err.Error()
+}
+
+func uploadRetryable(err error) bool {
+	var target *uploadError
+	if errors.As(err, &target) {
+		return target.retryable
+	}
+
+	return false
+}
+
+// put 通过PUT Object上传，使用Content-MD5由服务端校验内容，ETag只记录，加密或者分片时ETag不是内容的MD5
+func (u *uploader) put(ctx context.Context, path string) (*UploadRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, `打开日志文件[%s]`, path)
+	}
+	defer file.Close()
+
+	md5Hash, sha256Hash := md5.New(), sha256.New() //nolint:gosec // S3要求的Content-MD5
+
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), file)
+	if err != nil {
+		return nil, errors.Wrapf(err, `读取日志文件[%s]`, path)
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrapf(err, `读取日志文件[%s]`, path)
+	}
+
+	record := &UploadRecord{
+		File:   path,
+		Key:    u.config.Prefix + filepath.Base(path),
+		Size:   size,
+		SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+	}
+	md5Sum := md5Hash.Sum(nil)
+
+	endpoint := strings.TrimSuffix(u.config.Endpoint, `/`) + `/` + u.config.Bucket + `/` + record.Key
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, io.NopCloser(file))
+	if err != nil {
+		return nil, errors.Wrap(err, `生成请求`)
+	}
+
+	request.ContentLength = size
+	request.Header.Set(`Content-MD5`, base64.StdEncoding.EncodeToString(md5Sum))
+	request.Header.Set(`Content-Type`, contentTypeOf(path))
+	request.Header.Set(`X-Amz-Content-Sha256`, record.SHA256)
+	request.Header.Set(`X-Amz-Date`, time.Now().UTC().Format(amzDateLayout))
+
+	if u.config.AccessKey != `` {
+		signV4(request, u.config.AccessKey, u.config.SecretKey, u.config.Region)
+	}
+
+	response, err := u.client.Do(request)
+	if err != nil {
+		return nil, &uploadError{err: errors.Wrap(err, `发送请求`), retryable: true}
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+
+	switch {
+	case response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices:
+	case response.StatusCode == http.StatusRequestTimeout, response.StatusCode == http.StatusTooManyRequests,
+		response.StatusCode >= http.StatusInternalServerError:
+		return nil, &uploadError{err: errors.Errorf(`状态码%d:%s`, response.StatusCode, body), retryable: true}
+	default:
+		return nil, &uploadError{err: errors.Errorf(`状态码%d:%s`, response.StatusCode, body)}
+	}
+
+	record.ETag = strings.Trim(response.Header.Get(`ETag`), `"`)
+
+	record.UploadedAt = time.Now()
+
+	return record, nil
+}
+
+func contentTypeOf(path string) string {
+	switch compressedExtOf(path) {
+	case compressExt:
+		return `application/gzip`
+	case zstdExt:
+		return `application/zstd`
+	}
+
+	return `text/plain; charset=utf-8`
+}
+
+/*
+signV4 使用AWS Signature Version 4签名，签名请求中已有的所有头及Host
+请求头中需要已经设置X-Amz-Date及X-Amz-Content-Sha256
+参数:
+*	request  	*http.Request	请求
+*	accessKey	string       	访问密钥ID
+*	secretKey	string       	密钥
+*	region   	string       	区域
+*/
+func signV4(request *http.Request, accessKey, secretKey, region string) {
+	amzDate := request.Header.Get(`X-Amz-Date`)
+	date := amzDate[:8]
+	scope := date + `/` + region + `/s3/aws4_request`
+
+	headers := map[string]string{`host`: request.URL.Host}
+	for key, values := range request.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, `,`))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + `:` + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, `;`)
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		request.Header.Get(`X-Amz-Content-Sha256`),
+	}, "\n")
+
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := `AWS4-HMAC-SHA256` + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte(`AWS4`+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, `s3`)
+	key = hmacSHA256(key, `aws4_request`)
+
+	request.Header.Set(`Authorization`, `AWS4-HMAC-SHA256 Credential=`+accessKey+`/`+scope+
+		`, SignedHeaders=`+signedHeaders+`, Signature=`+hex.EncodeToString(hmacSHA256(key, stringToSign)))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(data))
+
+	return hash.Sum(nil)
+}
+
+// Close 停止接收新文件，等待队列中的文件上传完成，超过CloseTimeout时中断，未上传的文件可以通过pendingFiles获取
+func (u *uploader) Close() error {
+	u.lock.Lock()
+
+	if u.closed {
+		u.lock.Unlock()
+
+		return nil
+	}
+
+	u.closed = true
+	started := u.started
+	close(u.queue)
+	u.lock.Unlock()
+
+	defer u.cancel()
+
+	if !started {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(u.config.CloseTimeout) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-u.stopped:
+	case <-timer.C:
+		u.cancel()
+		<-u.stopped
+	}
+
+	return nil
+}
+
+// pendingFiles 关闭后仍未上传的文件
+func (u *uploader) pendingFiles() []string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	result := make([]string, 0, len(u.pending))
+	for path := range u.pending {
+		result = append(result, path)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
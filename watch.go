@@ -0,0 +1,184 @@
+package log2
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigWatcher 配置文件监听器，文件变化后将配置应用到日志器
+type ConfigWatcher struct {
+	path    string
+	logger  Logger
+	watcher *fsnotify.Watcher
+	last    []byte // 最近一次读取的文件内容，内容未变化时不重复应用
+	done    chan struct{}
+	wait    sync.WaitGroup
+}
+
+/*
+WatchConfig 监听配置文件，文件变化后重新读取并应用级别、LevelToPath、Routes、rotate、编码及HideConsole
+解析或应用失败时通过日志器输出错误，日志器保持原配置继续工作
+参数:
+*	path   	string        	配置文件路径，.toml使用toml解析，其他使用yaml解析
+*	logger 	Logger        	通过Config.Build构建的日志器
+返回值:
+*	watcher	*ConfigWatcher	监听器，不再使用时需要Close
+*	err    	error         	错误
+*/
+func WatchConfig(path string, logger Logger) (watcher *ConfigWatcher, err error) {
+	if target := instanceOf(logger); target == nil || !target.built() {
+		return nil, errors.New(`日志器未通过Config.Build构建,不支持重新加载配置`)
+	}
+
+	watcher = &ConfigWatcher{
+		path:   filepath.Clean(path),
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	if watcher.last, err = os.ReadFile(watcher.path); err != nil {
+		return nil, errors.Wrapf(err, `读取配置文件[%s]`, path)
+	}
+
+	if watcher.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return nil, errors.Wrap(err, `新建监听器`)
+	}
+
+	// 监听目录，编辑器先写临时文件再改名的保存方式也能感知
+	if err = watcher.watcher.Add(filepath.Dir(watcher.path)); err != nil {
+		_ = watcher.watcher.Close()
+
+		return nil, errors.Wrapf(err, `监听目录[%s]`, filepath.Dir(watcher.path))
+	}
+
+	watcher.wait.Add(1)
+
+	go watcher.run()
+
+	return watcher, nil
+}
+
+func (w *ConfigWatcher) run() {
+	defer w.wait.Done()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) != w.path || !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+
+			w.reload()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			w.logger.Error(`监听配置文件错误`, zap.String(fieldNamesOf(w.logger)[FieldConfig], w.path), zap.Error(err))
+		}
+	}
+}
+
+func (w *ConfigWatcher) reload() {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		w.logger.Error(`读取配置文件失败`, zap.String(fieldNamesOf(w.logger)[FieldConfig], w.path), zap.Error(err))
+
+		return
+	}
+
+	if bytes.Equal(data, w.last) {
+		return
+	}
+
+	config, err := parseConfig(w.path, data)
+	if err != nil {
+		w.logger.Error(`解析配置文件失败`, zap.String(fieldNamesOf(w.logger)[FieldConfig], w.path), zap.Error(err))
+
+		return
+	}
+
+	// 文件中没有级别时保留运行时通过SetLevel或者LevelHandler设置的级别
+	if err = instanceOf(w.logger).reload(config, hasLevelKey(w.path, data)); err != nil {
+		w.logger.Error(`应用配置失败`, zap.String(fieldNamesOf(w.logger)[FieldConfig], w.path), zap.Error(err))
+
+		return
+	}
+
+	w.last = data
+	w.logger.Info(`已重新加载配置`, zap.String(fieldNamesOf(w.logger)[FieldConfig], w.path))
+}
+
+/*
+Close 停止监听
+返回值:
+*	error	error	错误
+*/
+func (w *ConfigWatcher) Close() error {
+	close(w.done)
+	err := w.watcher.Close()
+	w.wait.Wait()
+
+	return errors.Wrap(err, `关闭监听器`)
+}
+
+/*
+ApplyConfig 将配置应用到已构建的日志器，重建所有输出后替换，已衍生出的日志器同样生效
+参数:
+*	logger	Logger 	通过Config.Build构建的日志器
+*	config	*Config	新配置
+返回值:
+*	error 	error  	错误
+*/
+func ApplyConfig(logger Logger, config *Config) error {
+	target := instanceOf(logger)
+	if target == nil {
+		return errors.New(`日志器未通过Config.Build构建,不支持重新加载配置`)
+	}
+
+	return target.reload(config, true)
+}
+
+// hasLevelKey 配置文件中是否设置了根级别
+func hasLevelKey(path string, data []byte) bool {
+	fields := map[string]interface{}{}
+
+	if strings.EqualFold(filepath.Ext(path), `.toml`) {
+		if toml.Unmarshal(data, &fields) != nil {
+			return true
+		}
+	} else if yaml.Unmarshal(data, &fields) != nil {
+		return true
+	}
+
+	for key := range fields {
+		if strings.EqualFold(key, `level`) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseConfig(path string, data []byte) (*Config, error) {
+	if strings.EqualFold(filepath.Ext(path), `.toml`) {
+		return NewConfigFromToml(data)
+	}
+
+	return NewConfigFromYamlData(bytes.NewReader(data))
+}
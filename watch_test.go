@@ -0,0 +1,150 @@
+package log2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestApplyConfig 测试将新配置应用到已构建的日志器
+func TestApplyConfig(t *testing.T) {
+	hook := &bufferHook{minLevel: zapcore.DebugLevel}
+	core, recorded := observer.New(zapcore.DebugLevel)
+	dir := t.TempDir()
+
+	root, err := (&Config{Service: "test", Level: zapcore.InfoLevel, HideConsole: true, Hooks: []Hook{hook}}).Build(core)
+	require.NoError(t, err)
+
+	derived := root.Derive(`mysql`).With(zap.String(`key`, `value`))
+	derived.Info(`console`)
+
+	require.NoError(t, ApplyConfig(root, &Config{
+		Service:     "test",
+		Level:       zapcore.DebugLevel,
+		HideConsole: true,
+		JSON:        true,
+		FilePath:    filepath.Join(dir, `app`),
+	}))
+
+	derived.Debug(`json`)
+	require.NoError(t, root.(*logger).instance.output.Load().core.Sync())
+
+	// 额外的core及Hooks保留，编码切换为JSON，字段保留
+	require.Equal(t, 2, recorded.Len())
+	require.Contains(t, hook.String(), `"M":"json"`)
+	require.Contains(t, hook.String(), `"key":"value"`)
+	require.Equal(t, zapcore.DebugLevel, root.Level())
+
+	data, err := os.ReadFile(filepath.Join(dir, `app.log`))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"M":"json"`)
+
+	t.Run("无效配置", func(t *testing.T) {
+		require.Error(t, ApplyConfig(root, &Config{TimeZone: `Invalid/Zone`}))
+		derived.Info(`仍然可用`)
+		require.Equal(t, 3, recorded.Len())
+	})
+
+	t.Run("未通过Build构建", func(t *testing.T) {
+		plain := NewLogger(zap.New(core), "plain", 0, false, false, nil, nil)
+		require.Error(t, ApplyConfig(plain, NewConfig()))
+		require.Error(t, ApplyConfig(plain.SetLevel(zapcore.InfoLevel), NewConfig()))
+
+		_, err = WatchConfig(filepath.Join(dir, `none.yaml`), plain)
+		require.Error(t, err)
+	})
+}
+
+// TestWatchConfig 测试监听配置文件
+func TestWatchConfig(t *testing.T) {
+	core, recorded := observer.New(zapcore.DebugLevel)
+	path := filepath.Join(t.TempDir(), `log.yaml`)
+
+	require.NoError(t, os.WriteFile(path, []byte("service: test\nlevel: info\nhideConsole: true\n"), 0o600))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+
+	cfg, err := NewConfigFromYamlData(file)
+	require.NoError(t, file.Close())
+	require.NoError(t, err)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	watcher, err := WatchConfig(path, root)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, watcher.Close())
+	}()
+
+	require.NoError(t, os.WriteFile(path, []byte("service: test\nlevel: debug\nhideConsole: true\n"), 0o600))
+	require.Eventually(t, func() bool {
+		return root.Level() == zapcore.DebugLevel
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 解析错误只输出日志，不影响日志器
+	require.NoError(t, os.WriteFile(path, []byte("level: [\n"), 0o600))
+	require.Eventually(t, func() bool {
+		for _, entry := range recorded.All() {
+			if strings.Contains(entry.Message, `解析配置文件失败`) {
+				return true
+			}
+		}
+
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, zapcore.DebugLevel, root.Level())
+}
+
+// TestApplyConfig_Inherit 测试重新加载时沿用回调及运行时设置的级别
+func TestApplyConfig_Inherit(t *testing.T) {
+	dir := t.TempDir()
+	onRotate := func(string) {}
+	routeOnRotate := func(string) {}
+
+	root, err := (&Config{
+		Service:     "test",
+		Level:       zapcore.InfoLevel,
+		HideConsole: true,
+		Rotate:      &RotateConfig{OnRotate: onRotate},
+		TaskID:      &TaskIDConfig{New: func() string { return `fixed` }},
+		Routes:      []RouteConfig{{Path: filepath.Join(dir, `sql.log`), Rotate: &RotateConfig{OnRotate: routeOnRotate}}},
+	}).Build()
+	require.NoError(t, err)
+
+	root.SetLevel(zapcore.DebugLevel)
+
+	data := []byte("service: test\nhideConsole: true\nroutes:\n  - path: " + filepath.Join(dir, `sql.log`) + "\n    rotate:\n      maxSize: 1\n")
+	require.False(t, hasLevelKey(`log.yaml`, data))
+	require.True(t, hasLevelKey(`log.yaml`, []byte("level: info\n")))
+	require.True(t, hasLevelKey(`log.toml`, []byte("Level = 'info'\n")))
+	require.False(t, hasLevelKey(`log.toml`, []byte("Service = 'test'\n")))
+
+	cfg, err := parseConfig(`log.yaml`, data)
+	require.NoError(t, err)
+
+	target := instanceOf(root)
+	require.NoError(t, target.reload(cfg, false))
+	require.Equal(t, zapcore.DebugLevel, root.Level(), `文件中没有级别时保留运行时的级别`)
+	require.NotNil(t, target.config.Rotate.OnRotate)
+	require.NotNil(t, target.config.Routes[0].Rotate.OnRotate)
+	require.Equal(t, `fixed`, target.output.Load().taskID.newID())
+
+	// 级别与当前配置相同时同样保留
+	require.NoError(t, ApplyConfig(root, &Config{Service: "test", Level: zapcore.InfoLevel, HideConsole: true}))
+	require.Equal(t, zapcore.DebugLevel, root.Level())
+
+	require.NoError(t, ApplyConfig(root, &Config{Service: "test", Level: zapcore.WarnLevel, HideConsole: true}))
+	require.Equal(t, zapcore.WarnLevel, root.Level())
+	require.NoError(t, root.Close())
+}
@@ -0,0 +1,152 @@
+package log2
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"regexp"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// maxPendingLine 不完整行的最大缓存字节数，超过时直接输出，避免一直没有换行时无限增长
+	maxPendingLine = 64 * 1024
+)
+
+var (
+	// stdLogHeader 标准库log输出的日期、时间及文件行号
+	stdLogHeader = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} )?(\d{2}:\d{2}:\d{2}(\.\d+)? )?(\S+\.go:\d+: )?`)
+)
+
+// lineWriter 按行输出到日志器的io.Writer
+type lineWriter struct {
+	lock   sync.Mutex
+	logger Logger
+	level  zapcore.Level
+	buf    []byte
+}
+
+/*
+NewWriter 生成按行输出到日志器的io.Writer，会去除标准库log的日期、时间及文件行号，
+调用位置为调用Write的位置，不完整的行会缓存到下一次写入或者Close，缓存超过64KB时直接输出
+参数:
+*	target          	Logger        	日志器
+*	level           	zapcore.Level 	输出的级别
+返回值:
+*	io.WriteCloser	io.WriteCloser	写入器
+*/
+func NewWriter(target Logger, level zapcore.Level) io.WriteCloser {
+	return newLineWriter(target, level, 0)
+}
+
+func newLineWriter(target Logger, level zapcore.Level, skip int) *lineWriter {
+	// 跳过Write及lineWriter.output
+	return &lineWriter{logger: target.AddCallerSkip(skip + 2), level: level}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	// 在锁外输出，Panic级别的日志被recover后不会残留在缓存中
+	for _, line := range w.lines(p) {
+		w.output(line)
+	}
+
+	return len(p), nil
+}
+
+// lines 追加到缓存并取出完整的行，缓存超过maxPendingLine时整体作为一行取出
+func (w *lineWriter) lines(p []byte) (result [][]byte) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	for {
+		index := bytes.IndexByte(w.buf, '\n')
+		if index < 0 {
+			break
+		}
+
+		result = append(result, append([]byte(nil), w.buf[:index]...))
+		w.buf = w.buf[index+1:]
+	}
+
+	if len(w.buf) >= maxPendingLine {
+		result = append(result, append([]byte(nil), w.buf...))
+		w.buf = w.buf[:0]
+	}
+
+	// 缓存已全部取出时释放底层数组
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+
+	return result
+}
+
+// Close 输出缓存的不完整行
+func (w *lineWriter) Close() error {
+	w.lock.Lock()
+	line := w.buf
+	w.buf = nil
+	w.lock.Unlock()
+
+	w.output(line)
+
+	return nil
+}
+
+func (w *lineWriter) output(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	line = line[len(stdLogHeader.Find(line)):]
+
+	if len(line) == 0 {
+		return
+	}
+
+	switch w.level {
+	case zapcore.DebugLevel:
+		w.logger.Debug(string(line))
+	case zapcore.InfoLevel:
+		w.logger.Info(string(line))
+	case zapcore.WarnLevel:
+		w.logger.Warn(string(line))
+	case zapcore.ErrorLevel, zapcore.DPanicLevel:
+		w.logger.Error(string(line))
+	case zapcore.PanicLevel:
+		w.logger.Panic(string(line))
+	case zapcore.FatalLevel:
+		w.logger.Fatal(string(line))
+	default:
+		w.logger.Info(string(line))
+	}
+}
+
+/*
+RedirectStdLog 将标准库log的默认日志器重定向到日志器，去除其日期、时间及前缀，
+调用位置为调用log.Printf等函数的位置
+参数:
+*	target 	Logger       	日志器
+*	level  	zapcore.Level	输出的级别
+返回值:
+*	restore	func()       	恢复标准库log原来的输出、标志及前缀
+*/
+func RedirectStdLog(target Logger, level zapcore.Level) (restore func()) {
+	var (
+		flags  = log.Flags()
+		prefix = log.Prefix()
+		writer = log.Writer()
+	)
+
+	// 跳过log.Printf及log.(*Logger).output
+	log.SetOutput(newLineWriter(target, level, 2))
+	log.SetFlags(0)
+	log.SetPrefix(``)
+
+	return func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+	}
+}
@@ -0,0 +1,111 @@
+package log2
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestNewWriter 测试按行输出并去除标准库log的头部
+func TestNewWriter(t *testing.T) {
+	cfg := &Config{Service: "test", Level: zapcore.DebugLevel, HideConsole: true}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	writer := NewWriter(root, zapcore.WarnLevel)
+
+	_, err = fmt.Fprint(writer, "第一行\r\n第二")
+	require.NoError(t, err)
+	require.Equal(t, 1, recorded.Len())
+
+	_, err = writer.Write([]byte("行\n\n"))
+	require.NoError(t, err)
+
+	std := log.New(writer, ``, log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	std.Printf(`第三行 %d`, 3)
+
+	_, err = writer.Write([]byte(`未完成`))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 4)
+
+	for i, message := range []string{`第一行`, `第二行`, `第三行 3`, `未完成`} {
+		require.Equal(t, message, logs[i].Message)
+		require.Equal(t, zapcore.WarnLevel, logs[i].Level)
+	}
+
+	require.Equal(t, `writer_test.go`, filepath.Base(logs[1].Caller.File))
+}
+
+// TestRedirectStdLog 测试重定向标准库log
+func TestRedirectStdLog(t *testing.T) {
+	cfg := &Config{Service: "test", Level: zapcore.DebugLevel, HideConsole: true}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	flags := log.Flags()
+	restore := RedirectStdLog(root.Derive(`std`), zapcore.InfoLevel)
+
+	log.Printf(`标准库 %s`, `a`)
+	log.Println(`多行`, "\n第二行")
+
+	restore()
+	require.Equal(t, flags, log.Flags())
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 3)
+	require.Equal(t, `标准库 a`, logs[0].Message)
+	require.Equal(t, `std`, logs[0].LoggerName)
+	require.Equal(t, zapcore.InfoLevel, logs[0].Level)
+	require.Equal(t, `writer_test.go`, filepath.Base(logs[0].Caller.File))
+	require.Equal(t, logs[0].Caller.Line+1, logs[1].Caller.Line)
+	require.Equal(t, `多行 `, logs[1].Message)
+}
+
+// TestNewWriter_PanicAndLongLine 测试Panic被recover后仍可写入，没有换行时按上限输出
+func TestNewWriter_PanicAndLongLine(t *testing.T) {
+	cfg := &Config{Service: "test", Level: zapcore.DebugLevel, HideConsole: true}
+	core, recorded := observer.New(zapcore.DebugLevel)
+
+	root, err := cfg.Build(core)
+	require.NoError(t, err)
+
+	writer := NewWriter(root, zapcore.PanicLevel)
+
+	require.Panics(t, func() {
+		_, _ = writer.Write([]byte("崩溃\n"))
+	})
+
+	require.Panics(t, func() {
+		_, _ = writer.Write([]byte("再次\n"))
+	}, `缓存及锁没有残留`)
+
+	logs := recorded.TakeAll()
+	require.Len(t, logs, 2)
+	require.Equal(t, `再次`, logs[1].Message)
+
+	writer = NewWriter(root, zapcore.InfoLevel)
+
+	_, err = writer.Write([]byte(strings.Repeat(`a`, maxPendingLine-1)))
+	require.NoError(t, err)
+	require.Equal(t, 0, recorded.Len())
+
+	_, err = writer.Write([]byte(`bb`))
+	require.NoError(t, err)
+	require.Equal(t, 1, recorded.Len(), `超过上限时直接输出`)
+	require.Len(t, recorded.All()[0].Message, maxPendingLine+1)
+	require.NoError(t, writer.Close())
+	require.Equal(t, 1, recorded.Len())
+}